@@ -0,0 +1,65 @@
+package gsetest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket"
+)
+
+// Eventually waits until the condition holds, namespace routines handle events
+// asynchronously so state changed by listeners should be asserted through it
+func Eventually(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if condition() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func inRoom(namespace *socket.Namespace, roomName string, client *Client) bool {
+	room, err := namespace.GetRoom(roomName)
+	return err == nil && room.HasClient(client.Id())
+}
+
+// AssertInRoom fails the test if the client doesn't join the room within the client timeout
+func AssertInRoom(t testing.TB, namespace *socket.Namespace, roomName string, client *Client) {
+	t.Helper()
+	if !Eventually(client.Timeout, func() bool { return inRoom(namespace, roomName, client) }) {
+		t.Fatalf("client %s is not in the room %s of %s", client.Id(), roomName, namespace.GetName())
+	}
+}
+
+// AssertNotInRoom fails the test if the client doesn't leave the room within the client timeout
+func AssertNotInRoom(t testing.TB, namespace *socket.Namespace, roomName string, client *Client) {
+	t.Helper()
+	if !Eventually(client.Timeout, func() bool { return !inRoom(namespace, roomName, client) }) {
+		t.Fatalf("client %s is in the room %s of %s", client.Id(), roomName, namespace.GetName())
+	}
+}
+
+// AssertStore fails the test if the store of the client doesn't hold the value within the client timeout
+func AssertStore(t testing.TB, client *Client, key string, expected interface{}) {
+	t.Helper()
+	var actual interface{}
+	matched := Eventually(client.Timeout, func() bool {
+		actual = client.Store().Get(key)
+		return reflect.DeepEqual(actual, expected)
+	})
+	if !matched {
+		t.Fatalf("store key %q of client %s is %#v, expected %#v", key, client.Id(), actual, expected)
+	}
+}
+
+// AssertNotInStore fails the test if the key isn't removed from the store of the client within the client timeout
+func AssertNotInStore(t testing.TB, client *Client, key string) {
+	t.Helper()
+	if !Eventually(client.Timeout, func() bool { return !client.Store().Has(key) }) {
+		t.Fatalf("store of client %s has the key %q", client.Id(), key)
+	}
+}
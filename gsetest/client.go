@@ -0,0 +1,299 @@
+package gsetest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"github.com/ppincak/gse/socket"
+	"github.com/ppincak/gse/socket/transport"
+	store "github.com/ppincak/gse/store"
+)
+
+var ErrClosed = errors.New("Client closed")
+
+// Client is a fake client, received packets are kept until they are awaited
+type Client struct {
+	// server side client
+	socket 		*socket.Client
+	conn 		*pipe
+	// duration to wait for packets
+	Timeout 	time.Duration
+	// received packets which were not awaited yet
+	inbox 		[]*transport.Packet
+	// all received packets
+	received 	[]*transport.Packet
+	// closed and replaced when a packet is received
+	changed 	chan struct{}
+	// last acknowledgment id
+	lastId 		int64
+	closed 		bool
+	mtx 		*sync.Mutex
+}
+
+func newClient(socketClient *socket.Client, conn *pipe, timeout time.Duration) *Client {
+	return &Client{
+		socket: 	socketClient,
+		conn: 		conn,
+		Timeout: 	timeout,
+		inbox: 		make([]*transport.Packet, 0),
+		received: 	make([]*transport.Packet, 0),
+		changed: 	make(chan struct{}),
+		mtx: 		new(sync.Mutex),
+	}
+}
+
+// reads the frames written by the server until the transport is closed,
+// frames buffered when the transport closes are delivered before the client is marked closed
+func (client *Client) receive() {
+	for {
+		select {
+			case frame := <-client.conn.out:
+				client.deliver(frame)
+			case <-client.conn.closec:
+				client.drain()
+				client.mtx.Lock()
+				client.closed = true
+				close(client.changed)
+				client.mtx.Unlock()
+				return
+		}
+	}
+}
+
+// delivers the frames left in the buffer
+func (client *Client) drain() {
+	for {
+		select {
+			case frame := <-client.conn.out:
+				client.deliver(frame)
+			default:
+				return
+		}
+	}
+}
+
+func (client *Client) deliver(frame transport.Frame) {
+	packet, err := transport.Decode(frame.Data)
+	if err != nil {
+		return
+	}
+	client.mtx.Lock()
+	client.inbox = append(client.inbox, packet)
+	client.received = append(client.received, packet)
+	close(client.changed)
+	client.changed = make(chan struct{})
+	client.mtx.Unlock()
+}
+
+// Id returns the session id of the client
+func (client *Client) Id() string {
+	return client.socket.GetSessionId()
+}
+
+// Socket returns the server side client
+func (client *Client) Socket() *socket.Client {
+	return client.socket
+}
+
+func (client *Client) Store() store.Store {
+	return client.socket.Store()
+}
+
+// Send writes the packet to the server
+func (client *Client) Send(packet *transport.Packet) error {
+	raw, err := transport.Encode(packet)
+	if err != nil {
+		return err
+	}
+	return client.conn.send(transport.Frame{Data: raw})
+}
+
+// Connect connects to the namespace and waits for the server to confirm it
+func (client *Client) Connect(namespaceName string) error {
+	return client.ConnectQuery(namespaceName, "")
+}
+
+// ConnectQuery connects to the namespace with the query string, a rejected
+// connection is returned as an error with the data sent by the server
+func (client *Client) ConnectQuery(namespaceName string, query string) error {
+	err := client.Send(&transport.Packet{
+		PacketType: transport.Connect,
+		Endpoint: 	namespaceName,
+		Qs: 		query,
+	})
+	if err != nil {
+		return err
+	}
+	packet, err := client.AwaitPacket(func(packet *transport.Packet) bool {
+		return packet.Endpoint == namespaceName && (packet.PacketType == transport.Connect ||
+			packet.PacketType == transport.ConnectError || packet.PacketType == transport.Error)
+	})
+	if err != nil {
+		return err
+	}
+	if packet.PacketType != transport.Connect {
+		return fmt.Errorf("Connection to %s rejected: %v", namespaceName, packet.Data)
+	}
+	return nil
+}
+
+// Disconnect leaves the namespace
+func (client *Client) Disconnect(namespaceName string) error {
+	return client.Send(&transport.Packet{
+		PacketType: transport.Disconnect,
+		Endpoint: 	namespaceName,
+	})
+}
+
+// the data of the packet is the single argument or all the arguments, as with socket.io clients,
+// so listeners registered with Listen receive it as well
+func eventPacket(packetType transport.PacketType, namespaceName string, event string, id int64, args []interface{}) *transport.Packet {
+	var data interface{} = args
+	if len(args) == 1 {
+		data = args[0]
+	}
+	return &transport.Packet{
+		PacketType: packetType,
+		Endpoint: 	namespaceName,
+		Name: 		event,
+		Id: 		id,
+		Data: 		data,
+		Args: 		args,
+	}
+}
+
+// Emit sends the event with positional arguments
+func (client *Client) Emit(namespaceName string, event string, args ...interface{}) error {
+	return client.Send(eventPacket(transport.Event, namespaceName, event, 0, args))
+}
+
+// EmitWithAck sends the event expecting an acknowledgment and waits for it
+func (client *Client) EmitWithAck(namespaceName string, event string, args ...interface{}) (*transport.Packet, error) {
+	client.mtx.Lock()
+	client.lastId++
+	id := client.lastId
+	client.mtx.Unlock()
+
+	err := client.Send(eventPacket(transport.Ack, namespaceName, event, id, args))
+	if err != nil {
+		return nil, err
+	}
+	return client.AwaitPacket(func(packet *transport.Packet) bool {
+		return packet.PacketType == transport.Ack && packet.Id == id && packet.Name == ""
+	})
+}
+
+// Acknowledge confirms the delivery of a reliable message
+func (client *Client) Acknowledge(packet *transport.Packet) error {
+	return client.Send(&transport.Packet{
+		PacketType: transport.Ack,
+		Endpoint: 	packet.Endpoint,
+		Id: 		packet.Id,
+	})
+}
+
+// History requests the recorded messages of a room the client is in and waits for them
+func (client *Client) History(namespaceName string, roomName string, query socket.HistoryQuery) ([]*socket.HistoryMessage, error) {
+	client.mtx.Lock()
+	client.lastId++
+	id := client.lastId
+	client.mtx.Unlock()
+
+	err := client.Send(&transport.Packet{
+		PacketType: transport.History,
+		Endpoint: 	namespaceName,
+		Name: 		roomName,
+		Id: 		id,
+		Data: 		query,
+	})
+	if err != nil {
+		return nil, err
+	}
+	packet, err := client.AwaitPacket(func(packet *transport.Packet) bool {
+		return packet.Endpoint == namespaceName && ((packet.PacketType == transport.History && packet.Id == id) ||
+			packet.PacketType == transport.Error)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if packet.PacketType == transport.Error {
+		return nil, fmt.Errorf("History of %s rejected: %v", roomName, packet.Data)
+	}
+	var messages []*socket.HistoryMessage
+	if err := DecodeArg(packet, 0, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Await waits for the event sent to the namespace
+func (client *Client) Await(namespaceName string, event string) (*transport.Packet, error) {
+	return client.AwaitPacket(func(packet *transport.Packet) bool {
+		return packet.PacketType == transport.Event && packet.Endpoint == namespaceName && packet.Name == event
+	})
+}
+
+// AwaitError waits for an error packet sent to the namespace
+func (client *Client) AwaitError(namespaceName string) (*transport.Packet, error) {
+	return client.AwaitPacket(func(packet *transport.Packet) bool {
+		return packet.PacketType == transport.Error && packet.Endpoint == namespaceName
+	})
+}
+
+// AwaitPacket waits for the first packet matching the predicate which wasn't awaited yet
+func (client *Client) AwaitPacket(match func(*transport.Packet) bool) (*transport.Packet, error) {
+	timer := time.NewTimer(client.Timeout)
+	defer timer.Stop()
+
+	for {
+		client.mtx.Lock()
+		for i, packet := range client.inbox {
+			if match(packet) {
+				client.inbox = append(client.inbox[:i:i], client.inbox[i + 1:]...)
+				client.mtx.Unlock()
+				return packet, nil
+			}
+		}
+		closed, changed := client.closed, client.changed
+		client.mtx.Unlock()
+
+		if closed {
+			return nil, ErrClosed
+		}
+		select {
+			case <-changed:
+			case <-timer.C:
+				return nil, fmt.Errorf("No matching packet received within %s", client.Timeout)
+		}
+	}
+}
+
+// Received returns all the packets received by the client
+func (client *Client) Received() []*transport.Packet {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	return append([]*transport.Packet(nil), client.received...)
+}
+
+// Close closes the connection, the server disconnects the client
+func (client *Client) Close() {
+	client.conn.Close()
+}
+
+// DecodeArg decodes the positional argument of the packet into the value
+func DecodeArg(packet *transport.Packet, i int, value interface{}) error {
+	args := packet.Args
+	if args == nil && packet.Data != nil {
+		args = []interface{}{packet.Data}
+	}
+	if i >= len(args) {
+		return fmt.Errorf("Packet has %d arguments", len(args))
+	}
+	raw, err := json.Marshal(args[i])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, value)
+}
@@ -0,0 +1,187 @@
+package gsetest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func TestNamespaceRunsOneRoutine(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	namespace, err := server.Namespace("/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listeners of a namespace never run concurrently when a single routine reads its events
+	var running, overlaps, handled int32
+	namespace.Listen("work", func(client *socket.SocketClient, data interface{}) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&handled, 1)
+	})
+
+	clients := make([]*Client, 4)
+	for i := range clients {
+		clients[i] = server.MustConnect("")
+		if err := clients[i].Connect("/chat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		for _, client := range clients {
+			if err := client.Emit("/chat", "work", i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !Eventually(time.Second, func() bool { return atomic.LoadInt32(&handled) == 40 }) {
+		t.Fatalf("Expected 40 handled events, got %d", atomic.LoadInt32(&handled))
+	}
+	if count := atomic.LoadInt32(&overlaps); count != 0 {
+		t.Fatalf("Listeners overlapped %d times", count)
+	}
+	if _, err := server.Namespace("/chat", nil); err == nil {
+		t.Fatal("Expected an error for a duplicate namespace")
+	}
+}
+
+func TestConnectRejected(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	client := server.MustConnect("")
+	if err := client.Connect("/missing"); err == nil {
+		t.Fatal("Expected the connection to a missing namespace to be rejected")
+	}
+}
+
+func TestEmitAndAwait(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	namespace, err := server.Namespace("/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace.Listen("echo", func(client *socket.SocketClient, data interface{}) {
+		client.Emit("echoed", data)
+	})
+	client := server.MustConnect("")
+	if err := client.Connect("/chat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Emit("/chat", "echo", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	packet, err := client.Await("/chat", "echoed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if err := DecodeArg(packet, 0, &text); err != nil || text != "hello" {
+		t.Fatalf("Expected hello, got %q (%v)", text, err)
+	}
+}
+
+func TestEmitWithAck(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	namespace, err := server.Namespace("/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace.Listen("sum", func(client *socket.SocketClient, data interface{}) {
+		var sum float64
+		for _, value := range data.([]interface{}) {
+			sum += value.(float64)
+		}
+		client.GetAck().SendArgs(sum)
+	})
+	client := server.MustConnect("")
+	if err := client.Connect("/chat"); err != nil {
+		t.Fatal(err)
+	}
+	packet, err := client.EmitWithAck("/chat", "sum", 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	if err := DecodeArg(packet, 0, &sum); err != nil || sum != 6 {
+		t.Fatalf("Expected 6, got %v (%v)", sum, err)
+	}
+}
+
+func TestRoomAssertions(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	namespace, err := server.Namespace("/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := namespace.AddRoom("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	namespace.Listen("join", func(client *socket.SocketClient, data interface{}) {
+		client.JoinRoom("lobby")
+		client.Store().Set("joined", true)
+	})
+	namespace.Listen("leave", func(client *socket.SocketClient, data interface{}) {
+		client.LeaveRoom("lobby")
+		client.Store().Delete("joined")
+	})
+	client := server.MustConnect("")
+	if err := client.Connect("/chat"); err != nil {
+		t.Fatal(err)
+	}
+	client.Emit("/chat", "join")
+	AssertInRoom(t, namespace, "lobby", client)
+	AssertStore(t, client, "joined", true)
+	client.Emit("/chat", "leave")
+	AssertNotInRoom(t, namespace, "lobby", client)
+	AssertNotInStore(t, client, "joined")
+}
+
+func TestAwaitTimeoutAndClose(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	client := server.MustConnect("")
+	client.Timeout = 20 * time.Millisecond
+	if _, err := client.Await("/", "never"); err == nil || err == ErrClosed {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+
+	client.Close()
+	if _, err := client.Await("/", "never"); err != ErrClosed {
+		t.Fatalf("Expected %v, got %v", ErrClosed, err)
+	}
+	if err := client.Send(&transport.Packet{PacketType: transport.Event, Endpoint: "/"}); err == nil {
+		t.Fatal("Expected sending on a closed client to fail")
+	}
+}
+
+func TestReceiveDrainsBufferedFrames(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		conn := newPipe()
+		client := newClient(nil, conn, 20 * time.Millisecond)
+		for j := 0; j < 3; j++ {
+			raw, _ := transport.Encode(&transport.Packet{PacketType: transport.Event, Endpoint: "/", Name: "last"})
+			conn.Write([]transport.Frame{{Data: raw}})
+		}
+		// the frames are buffered before the transport closes
+		conn.Close()
+		client.receive()
+
+		for j := 0; j < 3; j++ {
+			if _, err := client.Await("/", "last"); err != nil {
+				t.Fatalf("Expected the buffered packet %d, got %v", j, err)
+			}
+		}
+		if _, err := client.Await("/", "last"); err != ErrClosed {
+			t.Fatalf("Expected %v, got %v", ErrClosed, err)
+		}
+	}
+}
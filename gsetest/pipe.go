@@ -0,0 +1,71 @@
+package gsetest
+
+import (
+	"io"
+	"sync"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+const(
+	MemoryTransport = "memory"
+	// frames the server can write before the client reads them
+	pipeBufferSize = 1024
+)
+
+// in-memory transport, the server reads from in and writes to out
+type pipe struct {
+	in 		chan transport.Frame
+	out 	chan transport.Frame
+	closec 	chan struct{}
+	once 	*sync.Once
+}
+
+func newPipe() *pipe {
+	return &pipe{
+		in: 	make(chan transport.Frame),
+		out: 	make(chan transport.Frame, pipeBufferSize),
+		closec: make(chan struct{}),
+		once: 	new(sync.Once),
+	}
+}
+
+func (p *pipe) Name() string {
+	return MemoryTransport
+}
+
+func (p *pipe) Read() (transport.Frame, error) {
+	select {
+		case frame := <-p.in:
+			return frame, nil
+		case <-p.closec:
+			return transport.Frame{}, io.EOF
+	}
+}
+
+func (p *pipe) Write(frames []transport.Frame) error {
+	for _, frame := range frames {
+		select {
+			case p.out <- frame:
+			case <-p.closec:
+				return transport.ErrTransportClosed
+		}
+	}
+	return nil
+}
+
+func (p *pipe) Close() error {
+	p.once.Do(func() {
+		close(p.closec)
+	})
+	return nil
+}
+
+// sends a frame to the server
+func (p *pipe) send(frame transport.Frame) error {
+	select {
+		case p.in <- frame:
+			return nil
+		case <-p.closec:
+			return transport.ErrTransportClosed
+	}
+}
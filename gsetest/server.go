@@ -0,0 +1,69 @@
+// Package gsetest provides an in-memory server and fake clients for testing gse applications
+// without network sockets. Clients speak the gse protocol over an in-memory transport,
+// every wait is bounded by a timeout so tests fail instead of hanging.
+package gsetest
+
+import (
+	"net/http/httptest"
+	"time"
+	"github.com/ppincak/gse/socket"
+)
+
+const DefaultTimeout = time.Second
+
+type Server struct {
+	*socket.Server
+	// timeout of the clients created by the server
+	Timeout 	time.Duration
+}
+
+// NewServer creates and runs a server, nil configuration uses the defaults,
+// the protocol is always the gse protocol
+func NewServer(conf *socket.ServerConf) *Server {
+	if conf == nil {
+		conf = socket.DefaultConf()
+	}
+	copied := *conf
+	copied.Protocol = socket.GseProtocol
+	server := &Server{
+		Server: 	socket.NewServer(nil, &copied),
+		Timeout: 	DefaultTimeout,
+	}
+	server.Run()
+	return server
+}
+
+// Namespace adds a namespace, the running server starts its routine
+func (server *Server) Namespace(namespaceName string, conf *socket.NamespaceConf) (*socket.Namespace, error) {
+	return server.AddNamespace(namespaceName, conf)
+}
+
+func (server *Server) Close() {
+	server.Stop()
+}
+
+// Connect opens a client connected to the root namespace, the query
+// is available to the server through the handshake
+func (server *Server) Connect(query string) (*Client, error) {
+	target := "/"
+	if query != "" {
+		target += "?" + query
+	}
+	conn := newPipe()
+	socketClient, err := server.ServeTransport(conn, httptest.NewRequest("GET", target, nil))
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(socketClient, conn, server.Timeout)
+	go client.receive()
+	return client, nil
+}
+
+// MustConnect is like Connect but panics on error
+func (server *Server) MustConnect(query string) *Client {
+	client, err := server.Connect(query)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
@@ -1,20 +1,112 @@
 package socket
 
-import "github.com/ppincak/gse/socket/transport"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+)
 
 type Ack struct {
 	id        	int64
 	client		*Client
 	namespace   *Namespace
+	// context of the round-trip span
+	ctx 		context.Context
+	// span covering the time from receiving the event to sending the acknowledgment
+	span 		tracing.Span
+	// ends the span on the first response, the timeout or the close of the client
+	once 		*sync.Once
+	// guards the timer callbacks which can fire before they are assigned
+	mtx 		sync.Mutex
+	// ends the span when the acknowledgment isn't sent in time, nil with the noop tracer
+	timer 		*time.Timer
+	// stops the callback ending the span when the client closes
+	stopClose 	func() bool
+}
+
+// duration after which an unanswered acknowledgment span ends with an error,
+// the event timeout of the namespace is used when it is set
+const AckTimeout = 30 * time.Second
+
+var errAckTimeout = errors.New("Acknowledgment timed out")
+
+func newAck(packet *transport.Packet, client *Client, namespace *Namespace) *Ack {
+	tracer := client.server.tracer()
+	ctx, span := tracer.Start(tracer.Extract(client.ctx, tracing.Carrier(packet.Trace)), tracing.AckSpan)
+	span.SetAttribute(tracing.SessionKey, client.uuid)
+	span.SetAttribute(tracing.NamespaceKey, namespace.name)
+	span.SetAttribute(tracing.EventKey, packet.Name)
+	span.SetAttribute(tracing.AckIdKey, packet.Id)
+	ack := &Ack{
+		id: 		packet.Id,
+		client: 	client,
+		namespace: 	namespace,
+		ctx: 		ctx,
+		span: 		span,
+		once: 		new(sync.Once),
+	}
+	// noop spans don't need to be ended
+	if tracer == tracing.Noop() {
+		return ack
+	}
+	timeout := time.Duration(namespace.conf.EventTimeout)
+	if timeout <= 0 {
+		timeout = AckTimeout
+	}
+	// callbacks instead of a goroutine per acknowledgment
+	ack.mtx.Lock()
+	defer ack.mtx.Unlock()
+	ack.timer = time.AfterFunc(timeout, func() {
+		ack.end(errAckTimeout)
+	})
+	ack.stopClose = context.AfterFunc(client.ctx, func() {
+		ack.end(errClientClosed)
+	})
+	return ack
+}
+
+func (ack *Ack) end(err error) {
+	ack.once.Do(func() {
+		ack.mtx.Lock()
+		if ack.timer != nil {
+			ack.timer.Stop()
+			ack.stopClose()
+		}
+		ack.mtx.Unlock()
+		if err != nil {
+			ack.span.RecordError(err)
+		}
+		ack.span.End()
+	})
 }
 
 func (ack *Ack) SendData(data interface{}) {
-	if ack.client.isOpen() {
-		ack.client.SendPacket(&transport.Packet{
-			PacketType: transport.Ack,
-			Endpoint: 	ack.namespace.name,
-			Id: 		ack.id,
-			Data: 		data,
-		})
+	ack.send(&transport.Packet{
+		PacketType: transport.Ack,
+		Endpoint: 	ack.namespace.name,
+		Id: 		ack.id,
+		Data: 		data,
+	})
+}
+
+// sends the response with the trace context of the round-trip and ends its span
+func (ack *Ack) send(packet *transport.Packet) {
+	if !ack.client.isOpen() {
+		ack.end(errClientClosed)
+		return
 	}
-}
\ No newline at end of file
+	injectTrace(ack.client.server.tracer(), ack.ctx, packet)
+	ack.client.SendPacket(packet)
+	ack.end(nil)
+}
+
+// returns the context with the round-trip span as the parent
+func (ack *Ack) traceContext(ctx context.Context) context.Context {
+	tracer := ack.client.server.tracer()
+	carrier := tracing.Carrier{}
+	tracer.Inject(ack.ctx, carrier)
+	return tracer.Extract(ctx, carrier)
+}
@@ -0,0 +1,103 @@
+package socket
+
+import (
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// waits for the only ack span to end
+func endedAckSpan(t *testing.T, recorder *tracing.Recorder) tracing.RecordedSpan {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		spans := recorder.Find(tracing.AckSpan)
+		if len(spans) == 1 && !spans[0].End.IsZero() {
+			return spans[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Ack span didn't end, got %+v", spans)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func ackTestClient(t *testing.T, conf *NamespaceConf) (*Server, *SocketClient, *tracing.Recorder) {
+	recorder := tracing.NewRecorder()
+	serverConf := DefaultConf()
+	serverConf.Tracer = recorder
+	server := newTestServer(t, serverConf)
+	if _, err := server.AddNamespace("/acks", conf); err != nil {
+		t.Fatal(err)
+	}
+	client := connectTestClient(t, openTestClient(t, server, "/"), "/acks")
+	return server, client, recorder
+}
+
+func ackPacket() *transport.Packet {
+	return &transport.Packet{PacketType: transport.Ack, Endpoint: "/acks", Name: "sum", Id: 1}
+}
+
+func TestAckSpanEndsWhenSent(t *testing.T) {
+	server, client, recorder := ackTestClient(t, nil)
+	defer server.Stop()
+	newAck(ackPacket(), client.Client, client.namespace).SendData(3)
+
+	if span := endedAckSpan(t, recorder); len(span.Errors) != 0 {
+		t.Fatalf("Expected no errors, got %v", span.Errors)
+	}
+}
+
+func TestAckSpanEndsOnTimeout(t *testing.T) {
+	conf := DefaultNamespaceConf()
+	conf.EventTimeout = Duration(20 * time.Millisecond)
+	server, client, recorder := ackTestClient(t, conf)
+	defer server.Stop()
+	ack := newAck(ackPacket(), client.Client, client.namespace)
+
+	span := endedAckSpan(t, recorder)
+	if len(span.Errors) != 1 || span.Errors[0] != errAckTimeout {
+		t.Fatalf("Expected %v, got %v", errAckTimeout, span.Errors)
+	}
+	// a late response is still sent but doesn't touch the ended span
+	ack.SendData(3)
+	if spans := recorder.Find(tracing.AckSpan); len(spans[0].Errors) != 1 || spans[0].End != span.End {
+		t.Fatal("Late response changed the ended span")
+	}
+}
+
+func TestAckSpanEndsOnClose(t *testing.T) {
+	server, client, recorder := ackTestClient(t, nil)
+	defer server.Stop()
+	newAck(ackPacket(), client.Client, client.namespace)
+	client.Client.Disconnect()
+
+	span := endedAckSpan(t, recorder)
+	if len(span.Errors) != 1 || span.Errors[0] != errClientClosed {
+		t.Fatalf("Expected %v, got %v", errClientClosed, span.Errors)
+	}
+}
+
+func TestAckWithoutTracerStartsNoTimer(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	if _, err := server.AddNamespace("/acks", nil); err != nil {
+		t.Fatal(err)
+	}
+	client := connectTestClient(t, openTestClient(t, server, "/"), "/acks")
+	ack := newAck(ackPacket(), client.Client, client.namespace)
+	if ack.timer != nil || ack.stopClose != nil {
+		t.Fatal("Expected no timer with the noop tracer")
+	}
+	ack.SendData(3)
+}
+
+func TestAckSentStopsTimer(t *testing.T) {
+	server, client, _ := ackTestClient(t, nil)
+	defer server.Stop()
+	ack := newAck(ackPacket(), client.Client, client.namespace)
+	ack.SendData(3)
+	if ack.timer.Stop() || ack.stopClose() {
+		t.Fatal("Expected the callbacks to be stopped")
+	}
+}
@@ -0,0 +1,126 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// ArgsListener receives the positional arguments of an event
+type ArgsListener func(*SocketClient, []interface{})
+
+var socketClientType = reflect.TypeOf((*SocketClient)(nil))
+
+// ListenArgs registers a listener receiving the positional arguments of the event,
+// events sent with data only are passed as a single argument
+func (lst *Listeners) ListenArgs(event string, listener ArgsListener) Unsubscribe {
+	entry := newEventEntry(event, nil, false)
+	entry.ArgsListener = listener
+	return lst.add(entry)
+}
+
+// ListenTyped registers a handler of the form func(*SocketClient, A, B, ...) whose
+// parameters are decoded from the positional arguments of the event, missing
+// arguments are passed as zero values
+func (lst *Listeners) ListenTyped(event string, handler interface{}) (Unsubscribe, error) {
+	listener, err := typedListener(handler)
+	if err != nil {
+		return nil, err
+	}
+	return lst.ListenArgs(event, listener), nil
+}
+
+func typedListener(handler interface{}) (ArgsListener, error) {
+	value := reflect.ValueOf(handler)
+	if !value.IsValid() || value.Kind() != reflect.Func || value.IsNil() {
+		return nil, errors.New("Handler must be a non nil function")
+	}
+	handlerType := value.Type()
+	if handlerType.NumIn() == 0 || handlerType.In(0) != socketClientType {
+		return nil, errors.New("Handler must be a function accepting *SocketClient as the first parameter")
+	}
+	if handlerType.IsVariadic() {
+		return nil, errors.New("Variadic handlers are not supported")
+	}
+
+	return func(client *SocketClient, args []interface{}) {
+		in := make([]reflect.Value, handlerType.NumIn())
+		in[0] = reflect.ValueOf(client)
+		for i := 1; i < len(in); i++ {
+			param, err := decodeArg(args, i - 1, handlerType.In(i))
+			if err != nil {
+				client.logger.WithFields(Fields{NamespaceField: client.namespace.name}).Warnf("Failed to decode event argument %d: %v", i - 1, err)
+				client.sendError(client.namespace.name, makeComplexError(EventRejected, err))
+				return
+			}
+			in[i] = param
+		}
+		value.Call(in)
+	}, nil
+}
+
+// converts the argument to the parameter type through its json representation
+func decodeArg(args []interface{}, i int, paramType reflect.Type) (reflect.Value, error) {
+	if i >= len(args) || args[i] == nil {
+		return reflect.Zero(paramType), nil
+	}
+	arg := reflect.ValueOf(args[i])
+	if arg.Type().AssignableTo(paramType) {
+		return arg, nil
+	}
+	raw, err := json.Marshal(args[i])
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	param := reflect.New(paramType)
+	if err := json.Unmarshal(raw, param.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return param.Elem(), nil
+}
+
+// returns the positional arguments of a packet, data only packets yield a single argument
+func packetArgs(packet *transport.Packet) []interface{} {
+	if packet.Args != nil {
+		return packet.Args
+	}
+	if packet.Data != nil {
+		return []interface{}{packet.Data}
+	}
+	return []interface{}{}
+}
+
+func eventPacket(event string, namespace *Namespace, args []interface{}) *transport.Packet {
+	return &transport.Packet{
+		Name: 		event,
+		Args: 		args,
+		PacketType: transport.Event,
+		Endpoint: 	namespace.name,
+	}
+}
+
+// Emit sends the event with positional arguments to the client
+func (client *SocketClient) Emit(event string, args ...interface{}) {
+	client.namespace.emit(client.Context(), []*Client{client.Client}, eventPacket(event, client.namespace, args))
+}
+
+// Emit sends the event with positional arguments to all clients of the namespace
+func (namespace *Namespace) Emit(event string, args ...interface{}) {
+	namespace.emit(namespace.server.Context(), namespace.clientList(), eventPacket(event, namespace, args))
+}
+
+// Emit sends the event with positional arguments to all clients in the room
+func (room *Room) Emit(event string, args ...interface{}) {
+	room.send(eventPacket(event, room.namespace, args))
+}
+
+// SendArgs responds to the acknowledgment with positional arguments
+func (ack *Ack) SendArgs(args ...interface{}) {
+	ack.send(&transport.Packet{
+		PacketType: transport.Ack,
+		Endpoint: 	ack.namespace.name,
+		Id: 		ack.id,
+		Args: 		args,
+	})
+}
@@ -0,0 +1,129 @@
+package socket
+
+import (
+	"reflect"
+	"testing"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+type argsTestPoint struct {
+	X 	int 	`json:"x"`
+	Y 	int 	`json:"y"`
+}
+
+func argsTestClient(t *testing.T) (*Server, *SocketClient, *testTransport) {
+	server := newTestServer(t, nil)
+	if _, err := server.AddNamespace("/args", nil); err != nil {
+		t.Fatal(err)
+	}
+	client, conn := openTestConn(t, server, "/")
+	return server, connectTestClient(t, client, "/args"), conn
+}
+
+func TestTypedListenerInvalidHandlers(t *testing.T) {
+	var nilHandler func(*SocketClient)
+	handlers := map[string]interface{}{
+		"nil": 					nil,
+		"typed nil": 			nilHandler,
+		"not a function": 		42,
+		"no parameters": 		func() {},
+		"wrong first parameter": func(string) {},
+		"variadic": 			func(*SocketClient, ...int) {},
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			if _, err := typedListener(handler); err == nil {
+				t.Fatal("Expected an error")
+			}
+			if _, err := newListeners(nil).ListenTyped("event", handler); err == nil {
+				t.Fatal("Expected ListenTyped to fail")
+			}
+		})
+	}
+}
+
+func TestTypedListenerConvertsArguments(t *testing.T) {
+	server, client, _ := argsTestClient(t)
+	defer server.Stop()
+
+	var (
+		name 	string
+		count 	int
+		point 	argsTestPoint
+		tags 	[]string
+		raw 	map[string]interface{}
+	)
+	listener, err := typedListener(func(c *SocketClient, n string, i int, p argsTestPoint, s []string, m map[string]interface{}) {
+		if c != client {
+			t.Error("Expected the calling client")
+		}
+		name, count, point, tags, raw = n, i, p, s, m
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// arguments as they are decoded from json
+	listener(client, []interface{}{
+		"move",
+		float64(3),
+		map[string]interface{}{"x": float64(1), "y": float64(2)},
+		[]interface{}{"a", "b"},
+		map[string]interface{}{"k": "v"},
+	})
+
+	if name != "move" || count != 3 || point != (argsTestPoint{1, 2}) {
+		t.Fatalf("Unexpected arguments %q %d %+v", name, count, point)
+	}
+	if !reflect.DeepEqual(tags, []string{"a", "b"}) || !reflect.DeepEqual(raw, map[string]interface{}{"k": "v"}) {
+		t.Fatalf("Unexpected arguments %v %v", tags, raw)
+	}
+}
+
+func TestTypedListenerArity(t *testing.T) {
+	server, client, _ := argsTestClient(t)
+	defer server.Stop()
+
+	calls := 0
+	var first string
+	var second int
+	listener, _ := typedListener(func(c *SocketClient, s string, i int) {
+		calls++
+		first, second = s, i
+	})
+
+	// missing arguments are zero values
+	listener(client, []interface{}{"only"})
+	if calls != 1 || first != "only" || second != 0 {
+		t.Fatalf("Unexpected arguments %q %d", first, second)
+	}
+	// extra arguments are ignored
+	listener(client, []interface{}{"a", float64(1), "extra"})
+	if calls != 2 || first != "a" || second != 1 {
+		t.Fatalf("Unexpected arguments %q %d", first, second)
+	}
+	// nil arguments are zero values
+	listener(client, []interface{}{nil, nil})
+	if calls != 3 || first != "" || second != 0 {
+		t.Fatalf("Unexpected arguments %q %d", first, second)
+	}
+}
+
+func TestTypedListenerWrongArgumentType(t *testing.T) {
+	server, client, conn := argsTestClient(t)
+	defer server.Stop()
+
+	called := false
+	listener, _ := typedListener(func(c *SocketClient, i int, p argsTestPoint) {
+		called = true
+	})
+	listener(client, []interface{}{"not a number", map[string]interface{}{}})
+
+	if called {
+		t.Fatal("Expected the handler not to be called")
+	}
+	packet := conn.expect(t, transport.Error)
+	data, _ := packet.Data.(map[string]interface{})
+	if code, _ := data["errorCode"].(float64); int(code) != EventRejected || packet.Endpoint != "/args" {
+		t.Fatalf("Expected a rejected event error, got %+v", packet)
+	}
+}
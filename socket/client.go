@@ -1,16 +1,20 @@
 package socket
 
 import (
+	"context"
 	"github.com/ppincak/gse/store"
 	"github.com/ppincak/gse/utils"
 	"github.com/ppincak/gse/socket/transport"
+	"github.com/ppincak/gse/socket/stats"
 	"github.com/gorilla/websocket"
-	"github.com/sirupsen/logrus"
-	"encoding/json"
 	"sync"
 	"errors"
+	"net/url"
+	"time"
 )
 
+var errClientClosed = errors.New("Client is closed")
+
 type Client struct {
 	// uid of the room
 	uuid   		string
@@ -18,34 +22,87 @@ type Client struct {
 	server		*Server
 	// namespaces to which the client is connected
 	namespaces 	map[string] *Namespace
-	// rooms to which the client is connected
+	// rooms to which the client is connected by room id
 	rooms  		map[string]*Room
+	// rooms to which the client is connected by room name and id, names repeat across namespaces
+	roomNames 	map[string]map[string]*Room
 	// storage space
 	store		socket.Store
-	// webSocket connection
-	ws     		*websocket.Conn
+	// data of the opening http request
+	handshake 	*Handshake
+	// connection transport, replaced when the client is upgraded
+	transport 	transport.Transport
+	// protocol codec
+	codec 		transport.Codec
+	// heartbeat, nil if disabled
+	heartbeat 	*heartbeat
 	// writer channel
-	wc     		chan []byte
-	// stop channel
+	wc     		chan []transport.Frame
+	// transports to which the client is upgraded
+	upgradec 	chan transport.Transport
+	// closed when the client is closed
 	stopc		chan struct{}
-	// write mutex
+	// guards the maps, the transport and the flags, the lock order is Namespace, Client, Room,
+	// it is held while adding the client to or removing it from a room but never while calling into a namespace
 	mtx    		*sync.RWMutex
+	// serializes the frames of the old and the new transport during an upgrade
+	readMtx 	*sync.Mutex
 	// flag indicating if the connection is open
 	open   		bool
-}
-
-func NewClient(server *Server, ws *websocket.Conn, store socket.Store) (*Client) {
+	// flag indicating that the client explicitly connected to the root namespace
+	rootConnected bool
+	// unacknowledged reliable messages
+	outbox 		*outbox
+	// session whose parked outbox the client claims once it is bound to the same user
+	resumeId 	string
+	// id of the user bound to the client, empty if there is none
+	userId 		string
+	// inbound rate limiter
+	limiter		*clientLimiter
+	// inbound rate limiters of namespaces with own limits, used only from the read pump
+	nsLimiters 	map[string]*clientLimiter
+	// logger with the session id field
+	logger 		Logger
+	// context cancelled when the client disconnects
+	ctx 		context.Context
+	cancel 		context.CancelFunc
+}
+
+func NewClient(server *Server, conn transport.Transport, store socket.Store, handshake *Handshake) (*Client) {
+	return newClient(utils.GenerateUID(), server, conn, store, handshake)
+}
+
+func newClient(uuid string, server *Server, conn transport.Transport, store socket.Store, handshake *Handshake) (*Client) {
+	conf := server.config()
+	codec, heartbeat := server.newCodec(conf, uuid), newHeartbeat(conf)
+	// receive-only streams carry gse packets and can't answer pings
+	if conn.Name() == transport.SSETransport {
+		codec, heartbeat = transport.JsonCodec{}, nil
+	}
+	ctx, cancel := context.WithCancel(server.Context())
 	return &Client{
-		uuid: 		utils.GenerateUID(),
+		uuid: 		uuid,
 		namespaces: make(map[string]*Namespace),
 		server:     server,
 		rooms: 		make(map[string] *Room),
+		roomNames: 	make(map[string]map[string]*Room),
 		store: 		store,
-		ws:			ws,
-		wc: 		make(chan []byte),
+		handshake: 	handshake,
+		transport:	conn,
+		codec: 		codec,
+		heartbeat: 	heartbeat,
+		wc: 		make(chan []transport.Frame),
+		upgradec: 	make(chan transport.Transport),
 		stopc:      make(chan struct{}),
 		mtx: 		new(sync.RWMutex),
+		readMtx: 	new(sync.Mutex),
 		open:		true,
+		outbox: 	newOutbox(),
+		limiter:	newClientLimiter(conf.RateLimit),
+		nsLimiters: make(map[string]*clientLimiter),
+		logger: 	server.logger.WithFields(Fields{SessionField: uuid}),
+		ctx: 		ctx,
+		cancel: 	cancel,
 	};
 }
 
@@ -56,35 +113,130 @@ func (client *Client) wrap(Namespace *Namespace) *SocketClient {
 	}
 }
 
-func (client *Client) onPacket(bytes []byte) {
-	packet, err := transport.Decode(bytes)
+func (client *Client) onFrame(frame transport.Frame) {
+	client.limiter = client.limiter.update(client.server.config().RateLimit)
+	if !client.limiter.allowPacket(len(frame.Data)) {
+		client.throttle("", client.limiter.policy())
+		return
+	}
+
+	packets, err := client.codec.Decode(frame)
 	if err != nil {
-		logrus.Error(err)
+		client.server.stats.Inc(stats.PacketFailures)
+		client.logger.Warnf("%s: %v", Errors[FailedToParsePacket], err)
+		return
+	}
+	for _, packet := range packets {
+		client.onPacket(packet, len(frame.Data))
+		if !client.isOpen() {
+			return
+		}
+	}
+}
+
+func (client *Client) onPacket(packet *transport.Packet, size int) {
+	var err error
+	isEvent := packet.PacketType == transport.Event || packet.PacketType == transport.Ack
+	if isEvent && !client.limiter.allowEvent(packet.Name) {
+		client.throttle(packet.Endpoint, client.limiter.policy())
 		return
 	}
+	if limiter := client.namespaceLimiter(packet.Endpoint); limiter != nil {
+		if !limiter.allowPacket(size) || (isEvent && !limiter.allowEvent(packet.Name)) {
+			client.throttle(packet.Endpoint, limiter.policy())
+			return
+		}
+	}
 
 	switch packet.PacketType {
 		case transport.Connect:
-			err = client.onConnect(packet)
+			if err = client.onConnect(packet); err != nil {
+				client.sendConnectError(packet.Endpoint, err)
+			}
 		case transport.Disconnect:
 			err = client.onDisconnect(packet)
 		case transport.Event:
 			err = client.onEvent(packet)
 		case transport.Ack:
 			err = client.onAck(packet)
+		case transport.History:
+			err = client.onHistory(packet)
+		case transport.Ping:
+			client.writePacket(&transport.Packet{
+				PacketType: transport.Pong,
+				Data: 		packet.Data,
+			})
+		case transport.Pong:
+			client.heartbeat.pong()
 	}
 
 	if err != nil {
-		logrus.Error(err)
+		client.logger.WithFields(Fields{
+			NamespaceField: packet.Endpoint,
+			EventField: 	packet.Name,
+		}).Warnf("Failed to handle packet: %v", err)
+	}
+}
+
+// returns the limiter of the namespace the packet is addressed to, nil if it has no limits
+func (client *Client) namespaceLimiter(namespaceName string) *clientLimiter {
+	client.mtx.RLock()
+	namespace, ok := client.namespaces[namespaceName]
+	client.mtx.RUnlock()
+	if !ok || namespace.conf.RateLimit == nil {
+		return nil
+	}
+	limiter, ok := client.nsLimiters[namespaceName]
+	if !ok || limiter.conf != namespace.conf.RateLimit {
+		limiter = newClientLimiter(namespace.conf.RateLimit)
+		client.nsLimiters[namespaceName] = limiter
 	}
+	return limiter
+}
+
+func (client *Client) throttle(namespaceName string, policy LimitPolicy) {
+	client.server.stats.Inc(stats.ThrottledPackets)
+
+	switch policy {
+		case ErrorPolicy:
+			client.sendError(namespaceName, makeError(RateLimitExceeded))
+		case DisconnectPolicy:
+			client.logger.Warnf("Rate limit exceeded, disconnecting")
+			client.Disconnect()
+	}
+}
+
+// sends an error packet, errors other than Error are wrapped as rejected events
+func (client *Client) sendError(namespaceName string, err error) {
+	packetErr, ok := err.(Error)
+	if !ok {
+		packetErr = makeComplexError(EventRejected, err)
+	}
+	client.SendPacket(&transport.Packet{
+		PacketType: transport.Error,
+		Endpoint: 	namespaceName,
+		Data: 		packetErr,
+	})
+}
+
+func (client *Client) sendConnectError(namespaceName string, err error) {
+	packetErr, ok := err.(Error)
+	if !ok {
+		packetErr = makeComplexError(Unauthorized, err)
+	}
+	client.writePacket(&transport.Packet{
+		PacketType: transport.ConnectError,
+		Endpoint: 	namespaceName,
+		Data: 		packetErr,
+	})
 }
 
 func (client *Client) on(packet *transport.Packet) (*Namespace, error) {
 	if packet.Endpoint == "" {
 		return nil, errors.New("Packet missing namespace")
 	}
-	namespace, ok := client.namespaces[packet.Endpoint]
-	if !ok {
+	namespace := client.getNamespace(packet.Endpoint)
+	if namespace == nil {
 		return nil, errors.New("Namespace doesn't exist")
 	}
 	return namespace, nil
@@ -94,23 +246,45 @@ func (client *Client) onConnect(packet *transport.Packet) error {
 	if packet.Endpoint == "" {
 		return errors.New("Packet missing namespace")
 	}
+	if packet.Endpoint == client.server.Namespace.name {
+		return client.connectRoot(packet)
+	}
+	client.mtx.RLock()
 	_, ok := client.namespaces[packet.Endpoint]
+	client.mtx.RUnlock()
 	if ok {
 		return errors.New("Already connected to namespace")
 	}
-	namespace, ok := client.server.namespaces[packet.Endpoint]
-	if !ok {
-		return errors.New("Namespace doesn't exist")
+	return client.server.addNamespaceClient(client, packet)
+}
+
+// the root namespace is joined when the connection is opened,
+// an explicit connect acknowledges it and notifies the connect listeners
+func (client *Client) connectRoot(packet *transport.Packet) error {
+	client.mtx.Lock()
+	if client.rootConnected {
+		client.mtx.Unlock()
+		return errors.New("Already connected to namespace")
+	}
+	client.rootConnected = true
+	client.mtx.Unlock()
+
+	root := client.server.Namespace
+	if packet.Qs != "" {
+		client.handshake.setNamespaceQuery(root.name, packet.Qs)
+	}
+	client.notify(transport.Connect, root)
+	root.evc <- &listenerEvent{
+		listenerType: connectListener,
+		client: client,
 	}
-	namespace.addClient(client)
-	client.addNamespace(namespace)
 	return nil
 }
 
 func (client *Client) onDisconnect(packet *transport.Packet) error {
 	namespace, err := client.on(packet)
 	if err == nil {
-		namespace.removeClient(client)
+		namespace.disconnectClient(client)
 	}
 	return err
 }
@@ -128,6 +302,8 @@ func (client *Client) makeEvent(packet *transport.Packet) *listenerEvent {
 		client: 		client,
 		mame:		 	packet.Name,
 		data: 			packet.Data,
+		args: 			packetArgs(packet),
+		trace: 			packet.Trace,
 		listenerType: 	eventListener,
 	}
 }
@@ -137,42 +313,79 @@ func (client *Client) onAck(packet *transport.Packet) error {
 	if packet.Id == 0 {
 		return errors.New("bad packet id generated on the client side")
 	}
+	// acks without an event name acknowledge reliable messages
+	if packet.Name == "" {
+		client.acknowledge(packet.Id)
+		return nil
+	}
 	if err == nil {
 		event := client.makeEvent(packet)
-		event.ack = &Ack{
-			id: 		packet.Id,
-			client: 	client,
-			namespace:  namespace,
-		}
+		event.ack = newAck(packet, client, namespace)
 		namespace.evc <- event
 	}
 	return nil
 }
 
-func (client *Client) readPump() {
-	logrus.Infof("Client: %s readpump started", client.uuid)
-	defer logrus.Infof("Client: %s readpump stopped", client.uuid)
+func (client *Client) readPump(conn transport.Transport) {
+	client.logger.Debugf("Read pump started")
+	defer client.logger.Debugf("Read pump stopped")
 
 	for {
-		_, msg, err := client.ws.ReadMessage()
+		frame, err := conn.Read()
 
 		if err != nil {
+			// the transport was closed after an upgrade
+			if client.getTransport() != conn {
+				return
+			}
+			if err == websocket.ErrReadLimit {
+				client.server.stats.Inc(stats.PacketFailures)
+			}
 			client.disconnectError(err)
-			client.stopc <- struct{}{}
 			return
 		}
-		client.onPacket(msg)
+		client.readMtx.Lock()
+		client.onFrame(frame)
+		client.readMtx.Unlock()
+
+		if !client.isOpen() {
+			return
+		}
 	}
 }
 
 func (client *Client) writePump() {
-	logrus.Infof("Client: %s writepump started", client.uuid)
-	defer logrus.Infof("Client: %s writepump stopped", client.uuid)
+	client.logger.Debugf("Write pump started")
+	defer client.logger.Debugf("Write pump stopped")
+
+	var tick <-chan time.Time
+	if client.heartbeat != nil {
+		ticker := time.NewTicker(client.heartbeat.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
 	for {
 		select {
-			case msg := <-client.wc:
-				if err := client.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+			case frames := <-client.wc:
+				if err := client.getTransport().Write(frames); err != nil {
+					client.disconnectError(err)
+					return
+				}
+			case conn := <-client.upgradec:
+				if err := client.switchTransport(conn); err != nil {
+					client.disconnectError(err)
+					return
+				}
+			case <- tick:
+				if client.heartbeat.expired() {
+					client.logger.Infof("Heartbeat timed out")
+					// the read pump fails and disconnects the client
+					client.getTransport().Close()
+					continue
+				}
+				frames, _ := client.codec.Encode(&transport.Packet{PacketType: transport.Ping})
+				if err := client.getTransport().Write(frames); err != nil {
 					client.disconnectError(err)
 					return
 				}
@@ -182,95 +395,242 @@ func (client *Client) writePump() {
 	}
 }
 
+func (client *Client) getTransport() transport.Transport {
+	client.mtx.RLock()
+	defer client.mtx.RUnlock()
+	return client.transport
+}
+
+// Transport returns the name of the transport currently used by the client
+func (client *Client) Transport() string {
+	return client.getTransport().Name()
+}
+
+// probes the new transport and hands it over to the write pump, called from
+// the upgrade request so the current transport keeps working until the switch
+func (client *Client) upgrade(conn transport.Transport, timeout time.Duration) {
+	if prober, ok := client.codec.(transport.Prober); ok {
+		timer := time.AfterFunc(timeout, func() {
+			conn.Close()
+		})
+		err := prober.Probe(conn, client.getTransport())
+		timer.Stop()
+		if err != nil {
+			client.logger.Warnf("Transport upgrade failed: %v", err)
+			conn.Close()
+			return
+		}
+	}
+	select {
+		case client.upgradec <- conn:
+			go client.readPump(conn)
+		case <-client.stopc:
+			conn.Close()
+	}
+}
+
+// replaces the transport, frames which were not sent over the old transport are sent over the new one
+func (client *Client) switchTransport(conn transport.Transport) error {
+	client.mtx.Lock()
+	old := client.transport
+	client.transport = conn
+	client.mtx.Unlock()
+
+	old.Close()
+	client.logger.Infof("Client upgraded from %s to %s", old.Name(), conn.Name())
+	if polling, ok := old.(*transport.Polling); ok {
+		if pending := polling.Drain(); len(pending) > 0 {
+			return conn.Write(pending)
+		}
+	}
+	return nil
+}
+
 func (client *Client) isOpen() bool {
 	client.mtx.RLock()
 	defer client.mtx.RUnlock()
 	return client.open
 }
 
-func (client *Client) close() {
+// marks the client as closed and stops its pumps, returns false if it was already closed
+func (client *Client) close() bool {
 	client.mtx.Lock()
+	if !client.open {
+		client.mtx.Unlock()
+		return false
+	}
 	client.open = false
+	conn := client.transport
 	client.mtx.Unlock()
+
+	close(client.stopc)
+	client.cancel()
+	conn.Close()
+	return true
 }
 
 func (client *Client) destroy() {
+	client.mtx.Lock()
+	rooms, namespaces := client.rooms, client.namespaces
+	client.rooms = make(map[string]*Room)
+	client.roomNames = make(map[string]map[string]*Room)
+	client.namespaces = make(map[string]*Namespace)
+	client.mtx.Unlock()
+
 	// leave all rooms
-	for _, room := range client.rooms {
+	for _, room := range rooms {
 		room.removeClient(client)
 	}
 	// remove from namespaces
-	for _, namespace := range client.namespaces {
+	for _, namespace := range namespaces {
 		namespace.removeClient(client)
 	}
 
 	client.server.removeClient(client)
-
-	client.namespaces = make(map[string]*Namespace);
-	client.rooms = make(map[string]*Room)
+	client.server.releaseUser(client)
+	client.server.parkOutbox(client)
 }
 
 func (client *Client) Disconnect() {
-	client.ws.Close()
-	client.close()
-	client.destroy()
+	if client.close() {
+		client.destroy()
+	}
 }
 
 func (client *Client) disconnectError(err error) {
-	client.close()
+	if !client.close() {
+		return
+	}
 	client.destroy()
-	logrus.Error(err)
-	logrus.Errorf("Client connection closed, sessionid: %s", client.uuid)
+	if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		client.logger.Errorf("Client connection closed: %v", err)
+	} else {
+		client.logger.Infof("Client connection closed")
+	}
 }
 
 func (client *Client) Store() socket.Store {
 	return client.store
 }
 
+func (client *Client) Handshake() *Handshake {
+	return client.handshake
+}
+
 func (client *Client) GetSessionId() string {
 	return client.uuid
 }
 
-func (client *Client) addNamespace(namespace *Namespace) {
+func (client *Client) getNamespace(namespaceName string) *Namespace {
+	client.mtx.RLock()
+	defer client.mtx.RUnlock()
+	return client.namespaces[namespaceName]
+}
+
+// registers the namespace unless the client was closed, called with the namespace lock
+// held so a closing client either sees the namespace when it is destroyed or isn't added
+func (client *Client) addNamespace(namespace *Namespace) bool {
 	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	if !client.open {
+		return false
+	}
 	client.namespaces[namespace.name] = namespace
+	return true
+}
+
+func (client *Client) removeNamespace(namespace *Namespace) {
+	client.mtx.Lock()
+	if client.namespaces[namespace.name] == namespace {
+		delete(client.namespaces, namespace.name)
+	}
 	client.mtx.Unlock()
 }
 
-func (client *Client) joinRoom(room *Room) {
+// adds the client to the room and the room to the client indexes in one step,
+// fails if the client was closed or the room was destroyed
+func (client *Client) joinRoom(room *Room) error {
 	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	if !client.open {
+		return errClientClosed
+	}
+	if !room.addClient(client) {
+		return makeError(RoomDoesNotExist)
+	}
 	client.rooms[room.uuid] = room
-	client.mtx.Unlock()
-	room.addClient(client)
+	names, ok := client.roomNames[room.name]
+	if !ok {
+		names = make(map[string]*Room)
+		client.roomNames[room.name] = names
+	}
+	names[room.uuid] = room
+	return nil
 }
 
-func (client *Client) LeaveRoom(roomName string) {
-	for _, room := range client.rooms {
-		if room.name == roomName {
-			client.leaveRoom(room)
-			return
+// removes the room from the client indexes, must be called with the lock held
+func (client *Client) unindexRoom(room *Room) bool {
+	if _, ok := client.rooms[room.uuid]; !ok {
+		return false
+	}
+	delete(client.rooms, room.uuid)
+	if names := client.roomNames[room.name]; names != nil {
+		delete(names, room.uuid)
+		if len(names) == 0 {
+			delete(client.roomNames, room.name)
 		}
 	}
+	return true
 }
 
-func (client *Client) leaveRoom(room *Room) {
+// LeaveRoom leaves the rooms with the name in all namespaces of the client,
+// use SocketClient.LeaveRoom to leave the room of a single namespace
+func (client *Client) LeaveRoom(roomName string) {
 	client.mtx.Lock()
-	delete(client.rooms, room.uuid);
-	client.mtx.Unlock()
-	room.removeClient(client)
+	defer client.mtx.Unlock()
+	for _, room := range client.roomNames[roomName] {
+		client.unindexRoom(room)
+		room.removeClient(client)
+	}
 }
 
-func (client *Client) leaveAllRooms() {
-	for _, room := range client.rooms {
+func (client *Client) leaveRoom(room *Room) {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	if client.unindexRoom(room) {
 		room.removeClient(client)
 	}
+}
 
+// called by a destroyed room which already removed the client
+func (client *Client) forgetRoom(room *Room) {
 	client.mtx.Lock()
-	client.rooms = make(map[string]*Room)
+	client.unindexRoom(room)
 	client.mtx.Unlock()
 }
 
+// leaves the rooms of the namespace the client disconnected from
+func (client *Client) leaveNamespaceRooms(namespace *Namespace) {
+	client.mtx.Lock()
+	defer client.mtx.Unlock()
+	for _, room := range client.rooms {
+		if room.namespace == namespace {
+			client.unindexRoom(room)
+			room.removeClient(client)
+		}
+	}
+}
+
+// InRoom checks if the client is in a room with the name in any of its namespaces
+func (client *Client) InRoom(roomName string) bool {
+	client.mtx.RLock()
+	defer client.mtx.RUnlock()
+	return len(client.roomNames[roomName]) > 0
+}
+
 func (client *Client) GetAllRooms() []*Room {
+	client.mtx.RLock()
+	defer client.mtx.RUnlock()
 	rooms := make([]*Room, len(client.rooms))
 
 	i := 0
@@ -282,55 +642,55 @@ func (client *Client) GetAllRooms() []*Room {
 	return rooms
 }
 
-func (client *Client) disconnectFromNamespaces() {
-	for _, namespace := range client.namespaces {
-		namespace.removeClient(client)
-	}
-	client.mtx.Lock()
-	client.namespaces = make(map[string]*Namespace);
-	client.mtx.Unlock()
-}
-
-func (client *Client) notify(pType transport.PacketType, namespaceName string) {
-	client.SendPacket(&transport.Packet{
+func (client *Client) notify(pType transport.PacketType, namespace *Namespace) {
+	client.sendPacket(namespace, &transport.Packet{
 		PacketType: pType,
-		Endpoint:	namespaceName,
+		Endpoint:	namespace.name,
 	})
 }
 
+// SendPacket sends the packet through the outbound interceptors of the namespace it is addressed to
 func (client *Client) SendPacket(packet *transport.Packet) {
-	if !client.isOpen() {
-		return
-	}
-
-	raw, err := json.Marshal(packet)
-	if err != nil {
-		logrus.Debug(Errors[FailedToParsePacket], err)
-	}
-	client.wc <- raw
+	client.mtx.RLock()
+	namespace := client.namespaces[packet.Endpoint]
+	client.mtx.RUnlock()
+	client.sendPacket(namespace, packet)
 }
 
-func (client *Client) sendEvent(event string, data interface{}, namespaceName string) {
+func (client *Client) sendPacket(namespace *Namespace, packet *transport.Packet) {
 	if !client.isOpen() {
 		return
 	}
-
-	packet := &transport.Packet{
-		Name: event,
-		Data: data,
-		PacketType: transport.Event,
-		Endpoint: namespaceName,
+	if namespace != nil {
+		packet = namespace.intercept(client, packet, namespace.outboundInterceptors())
+		if packet == nil {
+			return
+		}
 	}
-	raw, err := json.Marshal(packet)
+	client.writePacket(packet)
+}
+
+// encodes the packet and queues it for writing, bypassing the interceptors
+func (client *Client) writePacket(packet *transport.Packet) {
+	frames, err := client.codec.Encode(packet)
 	if err != nil {
-		logrus.Debug(Errors[FailedToParsePacket], err)
+		client.logger.WithFields(Fields{EventField: packet.Name}).Errorf("%s: %v", Errors[FailedToParsePacket], err)
+		return
 	}
-	client.wc <- raw
+	client.sendFrames(frames)
 }
 
+
+// SendRaw sends the data as a single text frame
 func (client *Client) SendRaw(data []byte) {
-	if client.isOpen() {
-		client.wc <- data
+	client.sendFrames([]transport.Frame{{Data: data}})
+}
+
+// queues the frames for the write pump, frames sent after the client was closed are dropped
+func (client *Client) sendFrames(frames []transport.Frame) {
+	select {
+		case client.wc <- frames:
+		case <-client.stopc:
 	}
 }
 
@@ -338,6 +698,8 @@ type SocketClient struct {
 	*Client
 	ack 	  *Ack
 	namespace *Namespace
+	// context of the handled event, nil outside of event listeners
+	ctx 	  context.Context
 }
 
 func (n *SocketClient) JoinRoom(roomName string) error {
@@ -346,20 +708,32 @@ func (n *SocketClient) JoinRoom(roomName string) error {
 		return err
 	}
 
-	room.addClient(n.Client)
-	n.Client.mtx.Lock()
-	n.Client.rooms[room.uuid] = room
-	n.Client.mtx.Unlock()
-	return nil
+	return n.Client.joinRoom(room)
+}
+
+// LeaveRoom leaves the room of the namespace
+func (n *SocketClient) LeaveRoom(roomName string) {
+	if room, err := n.namespace.GetRoom(roomName); err == nil {
+		n.Client.leaveRoom(room)
+	}
 }
 
 func (client *SocketClient) Disconnect() {
-	client.namespace.removeClient(client.Client)
-	delete(client.namespaces, client.namespace.name)
+	client.namespace.disconnectClient(client.Client)
 }
 
 func (client *SocketClient) SendEvent(event string, data interface{}) {
-	client.sendEvent(event, data, client.namespace.name)
+	client.namespace.emit(client.Context(), []*Client{client.Client}, &transport.Packet{
+		Name: 		event,
+		Data: 		data,
+		PacketType: transport.Event,
+		Endpoint: 	client.namespace.name,
+	})
+}
+
+// Query returns the query string sent when connecting to the namespace
+func (client *SocketClient) Query() url.Values {
+	return client.handshake.NamespaceQuery(client.namespace.name)
 }
 
 func (client *SocketClient) HasAck() bool {
@@ -1,5 +1,16 @@
 package socket
 
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"encoding/json"
+	"errors"
+	"time"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+	"github.com/ppincak/gse/socket/transport/socketio"
+)
+
 const(
 	ServerName 			= "default"
 	EventBufferSize 	= 100
@@ -7,8 +18,51 @@ const(
 	WriteBufferSize 	= 1024
 	MaxNumOfClients 	= 10000
 	MaxNumOfRooms 		= 5000
+	MaxMessageSize 		= 0
+	HandshakeTimeout 	= 10 * time.Second
+	Protocol 			= transport.JsonProtocol
+	// socket.io heartbeat defaults
+	PingInterval 		= 25 * time.Second
+	PingTimeout 		= 20 * time.Second
+	// socket.io maxPayload announced when MaxMessageSize is unlimited
+	MaxPayload 			= 1000000
+	PollTimeout 		= 20 * time.Second
+)
+
+// supported wire protocols
+const(
+	GseProtocol 		= transport.JsonProtocol
+	SocketIOProtocol 	= socketio.Protocol
 )
 
+// Duration is a time.Duration which can be read from JSON either
+// as a string ("10s") or as a number of nanoseconds
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+		case float64:
+			*d = Duration(v)
+		case string:
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			*d = Duration(parsed)
+		default:
+			return errors.New("Invalid duration")
+	}
+	return nil
+}
+
 type ServerConf struct {
 	ServerName      	string	`json:"serverName"`
 	EventBufferSize 	int     `json:"eventBuffer"`
@@ -16,6 +70,42 @@ type ServerConf struct {
 	WriteBufferSize 	int 	`json:"writeBufferSize"`
 	MaxNumOfClients 	int32 	`json:"numberOfClients"`
 	MaxNumOfRooms   	int32	`json:"numberOfRooms"`
+	// maximum size of an inbound message in bytes, zero means unlimited
+	MaxMessageSize 		int64 	`json:"maxMessageSize"`
+	// inbound rate limits, nil means unlimited
+	RateLimit 			*RateLimitConf 	`json:"rateLimit"`
+	// allowed origin patterns, e.g. "https://*.example.com" or "example.com",
+	// empty means only same origin requests are accepted
+	AllowedOrigins 		[]string 			`json:"allowedOrigins"`
+	// supported subprotocols in order of preference
+	Subprotocols 		[]string 			`json:"subprotocols"`
+	// duration for the upgrade handshake to complete
+	HandshakeTimeout 	Duration 			`json:"handshakeTimeout"`
+	// headers added to the upgrade response
+	ResponseHeaders 	map[string]string 	`json:"responseHeaders"`
+	// reject upgrade requests which were not made over TLS
+	RequireTLS 			bool 				`json:"requireTLS"`
+	// trust the X-Forwarded-Proto header set by a TLS terminating proxy
+	TrustForwardedProto bool 				`json:"trustForwardedProto"`
+	// hook for customizing the upgrader after it was built from the configuration
+	UpgraderHook 		func(*websocket.Upgrader) 	`json:"-"`
+	// wire protocol, "gse" or "socketio"
+	Protocol 			string 				`json:"protocol"`
+	// interval between server pings, zero disables the heartbeat
+	// for the gse protocol and uses the socket.io default otherwise
+	PingInterval 		Duration 			`json:"pingInterval"`
+	// time to wait for a pong after a ping before the client is disconnected
+	PingTimeout 		Duration 			`json:"pingTimeout"`
+	// duration for which a long-polling request is held open, zero uses the default
+	PollTimeout 		Duration 			`json:"pollTimeout"`
+	// delivery of reliable messages, nil uses the defaults
+	Reliable 			*ReliableConf 		`json:"reliable"`
+	// tracing hooks, nil disables tracing
+	Tracer 				tracing.Tracer 		`json:"-"`
+	// parent of the server context, nil means context.Background()
+	BaseContext 		context.Context 	`json:"-"`
+	// logger used by the server, nil discards all messages
+	Logger 				Logger 				`json:"-"`
 }
 
 func DefaultConf() *ServerConf {
@@ -26,5 +116,9 @@ func DefaultConf() *ServerConf {
 		WriteBufferSize: 	WriteBufferSize,
 		MaxNumOfClients: 	MaxNumOfClients,
 		MaxNumOfRooms: 		MaxNumOfRooms,
+		MaxMessageSize: 	MaxMessageSize,
+		HandshakeTimeout: 	Duration(HandshakeTimeout),
+		Protocol: 			Protocol,
+		PollTimeout: 		Duration(PollTimeout),
 	}
 }
\ No newline at end of file
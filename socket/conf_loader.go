@@ -0,0 +1,328 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+const(
+	// prefix of the environment variables overriding the configuration
+	EnvPrefix = "GSE_"
+)
+
+// LoadConf reads the configuration from a JSON or YAML file, applies
+// environment variable overrides and validates the result.
+// Fields missing in the file keep their default values, an empty path
+// loads the defaults and environment variables only.
+//
+// Environment variables are named after the JSON keys, e.g.
+// GSE_NUMBER_OF_CLIENTS or GSE_RATE_LIMIT_PACKETS_PER_SECOND,
+// list values are separated by commas.
+func LoadConf(confPath string) (*ServerConf, error) {
+	conf := DefaultConf()
+
+	if confPath != "" {
+		data, err := ioutil.ReadFile(confPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeConf(data, filepath.Ext(confPath), conf); err != nil {
+			return nil, fmt.Errorf("Failed to parse configuration %s: %v", confPath, err)
+		}
+	}
+
+	if err := applyEnv(EnvPrefix, reflect.ValueOf(conf).Elem(), environ()); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func decodeConf(data []byte, ext string, conf *ServerConf) error {
+	switch strings.ToLower(ext) {
+		case ".json":
+			return json.Unmarshal(data, conf)
+		case ".yaml", ".yml":
+			var value interface{}
+			// strict decoding rejects duplicate keys
+			if err := yaml.UnmarshalStrict(data, &value); err != nil {
+				return err
+			}
+			value, err := yamlToJson(value)
+			if err != nil {
+				return err
+			}
+			// yaml is converted to json so the json tags stay the single source of field names
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(raw, conf)
+	}
+	return errors.New("Unsupported configuration format: " + ext)
+}
+
+// yaml decodes mappings with interface{} keys which json can't encode,
+// keys are converted to strings recursively
+func yamlToJson(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+		case map[interface{}]interface{}:
+			result := make(map[string]interface{}, len(value))
+			for key, item := range value {
+				switch key.(type) {
+					case map[interface{}]interface{}, []interface{}:
+						return nil, fmt.Errorf("yaml: unsupported mapping key %v", key)
+				}
+				converted, err := yamlToJson(item)
+				if err != nil {
+					return nil, err
+				}
+				result[fmt.Sprint(key)] = converted
+			}
+			return result, nil
+		case []interface{}:
+			result := make([]interface{}, len(value))
+			for i, item := range value {
+				converted, err := yamlToJson(item)
+				if err != nil {
+					return nil, err
+				}
+				result[i] = converted
+			}
+			return result, nil
+	}
+	return value, nil
+}
+
+type ConfError struct {
+	Problems 	[]string
+}
+
+func (e *ConfError) Error() string {
+	return "Invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ConfError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (conf *ServerConf) Validate() error {
+	confErr := &ConfError{}
+
+	if conf.ServerName == "" {
+		confErr.add("serverName must not be empty")
+	}
+	if conf.EventBufferSize < 0 {
+		confErr.add("eventBuffer must not be negative, got %d", conf.EventBufferSize)
+	}
+	if conf.ReadBufferSize < 0 {
+		confErr.add("readBufferSize must not be negative, got %d", conf.ReadBufferSize)
+	}
+	if conf.WriteBufferSize < 0 {
+		confErr.add("writeBufferSize must not be negative, got %d", conf.WriteBufferSize)
+	}
+	if conf.MaxNumOfClients < 0 {
+		confErr.add("numberOfClients must not be negative, got %d", conf.MaxNumOfClients)
+	}
+	if conf.MaxNumOfRooms < 0 {
+		confErr.add("numberOfRooms must not be negative, got %d", conf.MaxNumOfRooms)
+	}
+	if conf.MaxMessageSize < 0 {
+		confErr.add("maxMessageSize must not be negative, got %d", conf.MaxMessageSize)
+	}
+	if conf.HandshakeTimeout < 0 {
+		confErr.add("handshakeTimeout must not be negative, got %s", time.Duration(conf.HandshakeTimeout))
+	}
+	for _, pattern := range conf.AllowedOrigins {
+		if _, err := path.Match(pattern, ""); err != nil {
+			confErr.add("allowedOrigins contains an invalid pattern %q", pattern)
+		}
+	}
+	switch conf.Protocol {
+		case "", GseProtocol, SocketIOProtocol:
+		default:
+			confErr.add("protocol must be one of %q or %q, got %q", GseProtocol, SocketIOProtocol, conf.Protocol)
+	}
+	if conf.PingInterval < 0 {
+		confErr.add("pingInterval must not be negative, got %s", time.Duration(conf.PingInterval))
+	}
+	if conf.PingTimeout < 0 {
+		confErr.add("pingTimeout must not be negative, got %s", time.Duration(conf.PingTimeout))
+	}
+	if conf.PollTimeout < 0 {
+		confErr.add("pollTimeout must not be negative, got %s", time.Duration(conf.PollTimeout))
+	}
+	validateRateLimit(confErr, "rateLimit", conf.RateLimit)
+	validateReliable(confErr, "reliable", conf.Reliable)
+
+	if len(confErr.Problems) > 0 {
+		return confErr
+	}
+	return nil
+}
+
+func validateRateLimit(confErr *ConfError, field string, conf *RateLimitConf) {
+	if conf == nil {
+		return
+	}
+	if conf.PacketsPerSecond < 0 {
+		confErr.add("%s.packetsPerSecond must not be negative, got %v", field, conf.PacketsPerSecond)
+	}
+	if conf.PacketBurst < 0 {
+		confErr.add("%s.packetBurst must not be negative, got %d", field, conf.PacketBurst)
+	}
+	if conf.BytesPerSecond < 0 {
+		confErr.add("%s.bytesPerSecond must not be negative, got %v", field, conf.BytesPerSecond)
+	}
+	if conf.BytesBurst < 0 {
+		confErr.add("%s.bytesBurst must not be negative, got %d", field, conf.BytesBurst)
+	}
+	switch conf.Policy {
+		case "", DropPolicy, ErrorPolicy, DisconnectPolicy:
+		default:
+			confErr.add("%s.policy must be one of drop, error, disconnect, got %q", field, conf.Policy)
+	}
+	for event, eventConf := range conf.Events {
+		if eventConf.EventsPerSecond < 0 {
+			confErr.add("%s.events.%s.eventsPerSecond must not be negative, got %v", field, event, eventConf.EventsPerSecond)
+		}
+		if eventConf.EventBurst < 0 {
+			confErr.add("%s.events.%s.eventBurst must not be negative, got %d", field, event, eventConf.EventBurst)
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// applyEnv overrides struct fields from environment variables named
+// after their json tags, nested structs extend the prefix
+func applyEnv(prefix string, value reflect.Value, env map[string]string) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + envName(tag)
+		fieldValue := value.Field(i)
+
+		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+			if fieldValue.IsNil() {
+				if !hasEnvPrefix(env, name + "_") {
+					continue
+				}
+				fieldValue.Set(reflect.New(field.Type.Elem()))
+			}
+			if err := applyEnv(name + "_", fieldValue.Elem(), env); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := env[name]
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(fieldValue, raw); err != nil {
+			return fmt.Errorf("Invalid value of %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setEnvValue(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+		return nil
+	}
+
+	switch value.Kind() {
+		case reflect.String:
+			value.SetString(raw)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				return err
+			}
+			value.SetBool(parsed)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+			if err != nil {
+				return err
+			}
+			value.SetInt(parsed)
+		case reflect.Float32, reflect.Float64:
+			parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+			if err != nil {
+				return err
+			}
+			value.SetFloat(parsed)
+		case reflect.Slice:
+			if value.Type().Elem().Kind() != reflect.String {
+				return errors.New("unsupported list type")
+			}
+			items := make([]string, 0)
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+		case reflect.Map:
+			return json.Unmarshal([]byte(raw), value.Addr().Interface())
+		default:
+			return errors.New("unsupported type " + value.Type().String())
+	}
+	return nil
+}
+
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if i := strings.Index(entry, "="); i > 0 {
+			env[entry[:i]] = entry[i+1:]
+		}
+	}
+	return env
+}
+
+func hasEnvPrefix(env map[string]string, prefix string) bool {
+	for name := range env {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// converts a camelCase json key into UPPER_SNAKE_CASE
+func envName(tag string) string {
+	runes := []rune(tag)
+	var name []rune
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+	return string(name)
+}
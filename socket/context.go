@@ -0,0 +1,43 @@
+package socket
+
+import (
+	"context"
+	"time"
+)
+
+func baseContext(conf *ServerConf) context.Context {
+	if conf.BaseContext != nil {
+		return conf.BaseContext
+	}
+	return context.Background()
+}
+
+// Context returns the server context, it is cancelled when the server is stopped
+func (server *Server) Context() context.Context {
+	server.confMtx.RLock()
+	defer server.confMtx.RUnlock()
+	return server.ctx
+}
+
+// Context returns the client context, it is cancelled when the client disconnects
+// or the server is stopped
+func (client *Client) Context() context.Context {
+	return client.ctx
+}
+
+// Context returns the context of the handled event inside event listeners
+// and the client context otherwise
+func (client *SocketClient) Context() context.Context {
+	if client.ctx != nil {
+		return client.ctx
+	}
+	return client.Client.ctx
+}
+
+// derives the event context from the client context with the event timeout of the namespace
+func (namespace *Namespace) eventContext(client *Client) (context.Context, context.CancelFunc) {
+	if timeout := time.Duration(namespace.conf.EventTimeout); timeout > 0 {
+		return context.WithTimeout(client.ctx, timeout)
+	}
+	return context.WithCancel(client.ctx)
+}
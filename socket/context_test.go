@@ -0,0 +1,123 @@
+package socket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type contextTestKey struct{}
+
+func awaitDone(t *testing.T, ctx context.Context) {
+	select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the context to be cancelled")
+	}
+}
+
+func TestBaseContextCancelsClients(t *testing.T) {
+	base, cancel := context.WithCancel(context.WithValue(context.Background(), contextTestKey{}, "base"))
+	conf := DefaultConf()
+	conf.BaseContext = base
+	server := newTestServer(t, conf)
+	defer server.Stop()
+	client := openTestClient(t, server, "/")
+
+	if client.Context().Value(contextTestKey{}) != "base" {
+		t.Fatal("Expected the client context to derive from the base context")
+	}
+	cancel()
+	awaitDone(t, server.Context())
+	awaitDone(t, client.Context())
+}
+
+func TestStopCancelsContexts(t *testing.T) {
+	server := newTestServer(t, nil)
+	client := openTestClient(t, server, "/")
+	server.Stop()
+	awaitDone(t, server.Context())
+	awaitDone(t, client.Context())
+}
+
+func TestDisconnectCancelsClientContext(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	client := openTestClient(t, server, "/")
+	other := openTestClient(t, server, "/")
+
+	client.Disconnect()
+	awaitDone(t, client.Context())
+	if server.Context().Err() != nil || other.Context().Err() != nil {
+		t.Fatal("Expected only the client context to be cancelled")
+	}
+}
+
+func TestEventContextEndsWithListener(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	contexts := make(chan interface{}, 1)
+	namespace.Use(func(ctx *EventContext, next func()) {
+		ctx.WithContext(context.WithValue(ctx.Context(), contextTestKey{}, "middleware"))
+		next()
+	})
+	namespace.Listen("work", func(client *SocketClient, data interface{}) {
+		if client.Context().Value(contextTestKey{}) != "middleware" {
+			t.Error("Expected the context set by the middleware")
+		}
+		if client.Context().Err() != nil {
+			t.Error("Expected the event context to be active in the listener")
+		}
+		contexts <- client.Context()
+	})
+
+	sendTestEvent(t, conn, "work", nil)
+	awaitDone(t, awaitTestEvent(t, contexts).(context.Context))
+}
+
+func TestEventContextTimeout(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	conf := DefaultNamespaceConf()
+	conf.EventTimeout = Duration(20 * time.Millisecond)
+	namespace, err := server.AddNamespace("/mw", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, conn := openTestConn(t, server, "/")
+	connectTestClient(t, client, "/mw")
+
+	errs := make(chan interface{}, 1)
+	namespace.Listen("slow", func(client *SocketClient, data interface{}) {
+		<-client.Context().Done()
+		errs <- client.Context().Err()
+	})
+
+	sendTestEvent(t, conn, "slow", nil)
+	if err := awaitTestEvent(t, errs); err != context.DeadlineExceeded {
+		t.Fatalf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if client.Context().Err() != nil {
+		t.Fatal("Expected the client context to outlive the event")
+	}
+}
+
+func TestDisconnectCancelsEventContext(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	started := make(chan interface{}, 1)
+	errs := make(chan interface{}, 1)
+	namespace.Listen("slow", func(client *SocketClient, data interface{}) {
+		started <- client.Client
+		<-client.Context().Done()
+		errs <- client.Context().Err()
+	})
+
+	sendTestEvent(t, conn, "slow", nil)
+	awaitTestEvent(t, started).(*Client).Disconnect()
+	if err := awaitTestEvent(t, errs); err != context.Canceled {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+}
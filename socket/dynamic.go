@@ -0,0 +1,125 @@
+package socket
+
+import (
+	"errors"
+)
+
+// NamespaceMatcher decides if a namespace should be created for the name requested by the client
+type NamespaceMatcher func(name string, client *Client) bool
+
+// NamespaceConfFactory returns the configuration of a namespace created on demand,
+// nil uses the defaults
+type NamespaceConfFactory func(name string) *NamespaceConf
+
+// NamespaceSetup initializes a namespace created on demand, it runs before the namespace
+// accepts clients and must not add or remove namespaces
+type NamespaceSetup func(*Namespace)
+
+// DynamicNamespace creates namespaces lazily on the first connect, all of them
+// share its listeners and are removed when their last client disconnects
+type DynamicNamespace struct {
+	// listeners shared by all the created namespaces
+	*Listeners
+	// decides which names belong to this dynamic namespace
+	matcher 	NamespaceMatcher
+	// configuration of the created namespaces
+	conf 		NamespaceConfFactory
+	// namespace initialization
+	setup 		NamespaceSetup
+}
+
+var errNamespaceClosed = errors.New("Namespace was removed")
+
+// DynamicNamespace registers a dynamic namespace, a nil conf factory creates
+// the namespaces with the default configuration
+func (server *Server) DynamicNamespace(matcher NamespaceMatcher, conf NamespaceConfFactory, setup NamespaceSetup) *DynamicNamespace {
+	dynamic := &DynamicNamespace{
+		Listeners: 	newListeners(server.logger),
+		matcher: 	matcher,
+		conf: 		conf,
+		setup: 		setup,
+	}
+	server.nsMtx.Lock()
+	server.dynamic = append(server.dynamic, dynamic)
+	server.nsMtx.Unlock()
+	return dynamic
+}
+
+// returns the registered namespace or creates one from a matching dynamic namespace
+func (server *Server) getNamespace(namespaceName string, client *Client) (*Namespace, error) {
+	server.nsMtx.RLock()
+	namespace, ok := server.namespaces[namespaceName]
+	dynamics := server.dynamic
+	server.nsMtx.RUnlock()
+	if ok {
+		return namespace, nil
+	}
+
+	for _, dynamic := range dynamics {
+		if !dynamic.matcher(namespaceName, client) {
+			continue
+		}
+		conf, err := dynamic.namespaceConf(namespaceName)
+		if err != nil {
+			server.logger.WithFields(Fields{NamespaceField: namespaceName}).Errorf("Invalid dynamic namespace configuration: %v", err)
+			return nil, makeError(NamespaceDoesNotExist)
+		}
+
+		server.nsMtx.Lock()
+		// another client could have created the namespace in the meantime
+		if existing, ok := server.namespaces[namespaceName]; ok {
+			server.nsMtx.Unlock()
+			return existing, nil
+		}
+		namespace = newNamespace(namespaceName, server, conf)
+		namespace.Listeners = dynamic.Listeners
+		namespace.dynamic = dynamic
+		// setup doesn't touch the registry so it runs under its lock, only on the
+		// registered namespace and before any client can see it
+		if dynamic.setup != nil {
+			dynamic.setup(namespace)
+		}
+		server.namespaces[namespaceName] = namespace
+		server.nsMtx.Unlock()
+
+		namespace.logger.Infof("Created dynamic namespace")
+		namespace.start()
+		return namespace, nil
+	}
+	return nil, makeError(NamespaceDoesNotExist)
+}
+
+// returns the validated configuration of the namespace, nil uses the defaults
+func (dynamic *DynamicNamespace) namespaceConf(namespaceName string) (*NamespaceConf, error) {
+	if dynamic.conf == nil {
+		return nil, nil
+	}
+	conf := dynamic.conf(namespaceName)
+	if conf != nil {
+		if err := conf.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// removes a dynamic namespace which the client failed to join if no other client is in it
+func (server *Server) discardDynamic(namespace *Namespace) {
+	if namespace.dynamic != nil && server.releaseDynamic(namespace) {
+		namespace.Stop()
+	}
+}
+
+// removes a dynamic namespace without clients, returns true if it was removed
+func (server *Server) releaseDynamic(namespace *Namespace) bool {
+	if !namespace.closeIfEmpty() {
+		return false
+	}
+	server.nsMtx.Lock()
+	if server.namespaces[namespace.name] == namespace {
+		delete(server.namespaces, namespace.name)
+	}
+	server.nsMtx.Unlock()
+	namespace.logger.Infof("Removed empty dynamic namespace")
+	return true
+}
@@ -0,0 +1,269 @@
+package socket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+var errAmbiguousTarget = errors.New("Envelope must select at most one of room, user and session")
+
+var errNoEmitterAuthorize = errors.New("Emitter handler has no authorize function")
+
+// Envelope describes an event published through the emitter, without a room,
+// user or session it is sent to all the clients of the namespace
+type Envelope struct {
+	// namespace of the event, empty means the root namespace
+	Namespace 	string 			`json:"namespace,omitempty"`
+	// room of the namespace receiving the event
+	Room 		string 			`json:"room,omitempty"`
+	// user whose sessions receive the event
+	User 		string 			`json:"user,omitempty"`
+	// session receiving the event
+	Session 	string 			`json:"session,omitempty"`
+	// event name
+	Event 		string 			`json:"event"`
+	// event data, used when there are no positional arguments
+	Data 		interface{} 	`json:"data,omitempty"`
+	// positional event arguments
+	Args 		[]interface{} 	`json:"args,omitempty"`
+	// deliver the event at least once
+	Reliable 	bool 			`json:"reliable,omitempty"`
+}
+
+// PublishResult is the response of the emitter endpoints
+type PublishResult struct {
+	// number of clients the event was sent to
+	Recipients 	int 	`json:"recipients"`
+	// reason of the failure
+	Error 		string 	`json:"error,omitempty"`
+}
+
+// Emitter publishes events to the clients without a client of its own,
+// it is safe for use from any goroutine
+type Emitter struct {
+	server 	*Server
+}
+
+func (server *Server) Emitter() *Emitter {
+	return &Emitter{
+		server: server,
+	}
+}
+
+// Emit sends the event with positional arguments to all clients of the namespace
+func (emitter *Emitter) Emit(namespaceName string, event string, args ...interface{}) (int, error) {
+	return emitter.Publish(&Envelope{Namespace: namespaceName, Event: event, Args: args})
+}
+
+// EmitToRoom sends the event with positional arguments to all clients in the room
+func (emitter *Emitter) EmitToRoom(namespaceName string, roomName string, event string, args ...interface{}) (int, error) {
+	return emitter.Publish(&Envelope{Namespace: namespaceName, Room: roomName, Event: event, Args: args})
+}
+
+// EmitToUser sends the event with positional arguments to the sessions of the user in the namespace
+func (emitter *Emitter) EmitToUser(namespaceName string, userId string, event string, args ...interface{}) (int, error) {
+	return emitter.Publish(&Envelope{Namespace: namespaceName, User: userId, Event: event, Args: args})
+}
+
+// Publish sends the event described by the envelope, returns the number of recipients
+func (emitter *Emitter) Publish(envelope *Envelope) (int, error) {
+	if envelope.Event == "" {
+		return 0, errors.New("Envelope missing event")
+	}
+	selectors := 0
+	for _, selector := range []string{envelope.Room, envelope.User, envelope.Session} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return 0, errAmbiguousTarget
+	}
+
+	server := emitter.server
+	namespaceName := envelope.Namespace
+	if namespaceName == "" {
+		namespaceName = server.Namespace.name
+	}
+	target := &Target{server: server, namespace: namespaceName}
+	namespace := target.getNamespace()
+	if namespace == nil {
+		return 0, makeError(NamespaceDoesNotExist)
+	}
+
+	packet := &transport.Packet{
+		Name: 		envelope.Event,
+		Data: 		envelope.Data,
+		PacketType: transport.Event,
+		Endpoint: 	namespace.name,
+	}
+	if envelope.Args != nil {
+		packet = eventPacket(envelope.Event, namespace, envelope.Args)
+	}
+
+	var clients []*Client
+	switch {
+		case envelope.Room != "":
+			room, err := namespace.GetRoom(envelope.Room)
+			if err != nil {
+				return 0, makeError(RoomDoesNotExist)
+			}
+			room.record(packet)
+			clients = room.clientList()
+		case envelope.User != "":
+			clients = server.ToUser(envelope.User).In(namespace.name).GetClients()
+		case envelope.Session != "":
+			clients = server.ToSession(envelope.Session).In(namespace.name).GetClients()
+		default:
+			clients = namespace.clientList()
+	}
+
+	if envelope.Reliable {
+		namespace.emitReliable(server.Context(), clients, packet)
+	} else {
+		namespace.emit(server.Context(), clients, packet)
+	}
+	return len(clients), nil
+}
+
+// EmitterHandler publishes the JSON envelopes POSTed to it and answers with a PublishResult,
+// it is meant for backend services and must not be exposed to browsers. The authorize function
+// is required, a nil function rejects every request so a handler is never left open by mistake
+func (server *Server) EmitterHandler(authorize func(r *http.Request) error) http.Handler {
+	emitter := server.Emitter()
+	if authorize == nil {
+		server.logger.Warnf("%v, all requests are rejected", errNoEmitterAuthorize)
+		authorize = func(r *http.Request) error {
+			return errNoEmitterAuthorize
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := authorize(r); err != nil {
+			writePublishResult(w, http.StatusForbidden, PublishResult{Error: err.Error()})
+			return
+		}
+
+		var envelope Envelope
+		body := http.MaxBytesReader(w, r.Body, maxPayload(server.config()))
+		if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+			writePublishResult(w, http.StatusBadRequest, PublishResult{Error: err.Error()})
+			return
+		}
+		recipients, err := emitter.Publish(&envelope)
+		if err != nil {
+			writePublishResult(w, http.StatusBadRequest, PublishResult{Error: err.Error()})
+			return
+		}
+		writePublishResult(w, http.StatusOK, PublishResult{Recipients: recipients})
+	})
+}
+
+func writePublishResult(w http.ResponseWriter, status int, result PublishResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// ServeEmitter publishes the envelopes read from the connections of the listener, every
+// connection sends one JSON envelope per line and receives one PublishResult line for each,
+// it returns when the listener is closed
+func (server *Server) ServeEmitter(listener net.Listener) error {
+	emitter := server.Emitter()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.serveEmitterConn(emitter, conn)
+	}
+}
+
+func (server *Server) serveEmitterConn(emitter *Emitter, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), int(maxPayload(server.config())))
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var result PublishResult
+		var envelope Envelope
+		if err := json.Unmarshal(line, &envelope); err != nil {
+			result.Error = err.Error()
+		} else if result.Recipients, err = emitter.Publish(&envelope); err != nil {
+			result.Error = err.Error()
+		}
+		if err := encoder.Encode(result); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		server.logger.Warnf("Emitter connection failed: %v", err)
+	}
+}
+
+// unix listener removing the socket file moved into place when closed
+type emitterListener struct {
+	*net.UnixListener
+	path 	string
+}
+
+func (listener *emitterListener) Close() error {
+	err := listener.UnixListener.Close()
+	os.Remove(listener.path)
+	return err
+}
+
+// ListenEmitter serves the emitter on a Unix socket at the path, a stale socket file is
+// replaced and the socket is accessible only to the owner and the group, closing the
+// returned listener stops the endpoint and removes the socket
+func (server *Server) ListenEmitter(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode() & os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	// the socket is created in a private directory and moved to the path only after its
+	// permissions are restricted, so nobody else can connect in the meantime
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".emitter")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	created := filepath.Join(dir, "emitter.sock")
+	unixListener, err := net.ListenUnix("unix", &net.UnixAddr{Name: created, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	unixListener.SetUnlinkOnClose(false)
+	if err = os.Chmod(created, 0660); err == nil {
+		err = os.Rename(created, path)
+	}
+	if err != nil {
+		unixListener.Close()
+		return nil, err
+	}
+	listener := &emitterListener{
+		UnixListener: 	unixListener,
+		path: 			path,
+	}
+	go func() {
+		if err := server.ServeEmitter(listener); err != nil {
+			server.logger.Debugf("Emitter endpoint stopped: %v", err)
+		}
+	}()
+	return listener, nil
+}
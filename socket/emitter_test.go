@@ -0,0 +1,106 @@
+package socket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenEmitter(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	dir, err := ioutil.TempDir("", "gse-emitter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "emitter.sock")
+
+	listener, err := server.ListenEmitter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() & os.ModeSocket == 0 || info.Mode().Perm() != 0660 {
+		t.Fatalf("Expected a socket with mode 0660, got %s", info.Mode())
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("Expected the private directory to be removed, found %d entries", len(entries))
+	}
+
+	openTestClient(t, server, "/")
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(`{"event":"news","data":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	var result PublishResult
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &result)
+	}
+	if err != nil || result.Recipients != 1 {
+		t.Fatalf("Expected one recipient, got %+v (%v)", result, err)
+	}
+
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the socket to be removed, got %v", err)
+	}
+}
+
+func postEnvelope(handler http.Handler, token string) (*httptest.ResponseRecorder, PublishResult) {
+	r := httptest.NewRequest("POST", "/emit", strings.NewReader(`{"event":"news","data":"hello"}`))
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var result PublishResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w, result
+}
+
+func TestEmitterHandlerAuthorize(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	openTestClient(t, server, "/")
+	handler := server.EmitterHandler(func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "secret" {
+			return errors.New("Unauthorized")
+		}
+		return nil
+	})
+
+	if w, result := postEnvelope(handler, "secret"); w.Code != http.StatusOK || result.Recipients != 1 {
+		t.Fatalf("Expected one recipient, got %d %+v", w.Code, result)
+	}
+	if w, result := postEnvelope(handler, "wrong"); w.Code != http.StatusForbidden || result.Recipients != 0 {
+		t.Fatalf("Expected 403, got %d %+v", w.Code, result)
+	}
+}
+
+func TestEmitterHandlerWithoutAuthorizeFailsClosed(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	openTestClient(t, server, "/")
+
+	w, result := postEnvelope(server.EmitterHandler(nil), "secret")
+	if w.Code != http.StatusForbidden || result.Error != errNoEmitterAuthorize.Error() {
+		t.Fatalf("Expected 403, got %d %+v", w.Code, result)
+	}
+}
@@ -7,12 +7,30 @@ var Errors = map[int]string{
 	RoomDoesNotExist: 		"Room doesnt exist",
 	ServerAlreadyRunning:	"Server is already running",
 	FailedToParsePacket: 	"Failed to parse message",
+	RateLimitExceeded: 		"Rate limit exceeded",
+	MaxClientsReached: 		"Maximum number of clients reached",
+	MaxRoomsReached: 		"Maximum number of rooms reached",
+	EventRejected: 			"Event rejected",
+	Unauthorized: 			"Unauthorized",
+	NamespaceDoesNotExist: 	"Namespace doesn't exist",
+	HistoryUnavailable: 	"History is not available",
+	RoomAlreadyExists: 		"Room already exists",
+	InvalidRoomName: 		"Invalid room name",
 }
 
 const (
 	RoomDoesNotExist 		= iota
 	FailedToParsePacket
 	ServerAlreadyRunning
+	RateLimitExceeded
+	MaxClientsReached
+	MaxRoomsReached
+	EventRejected
+	Unauthorized
+	NamespaceDoesNotExist
+	HistoryUnavailable
+	RoomAlreadyExists
+	InvalidRoomName
 )
 
 type Error struct {
@@ -1,19 +1,37 @@
 package socket
 
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
 type EventListener func(*SocketClient, interface{})
 type ConnectListener func(*SocketClient)
 type DisconnectListener func(*SocketClient)
+type AnyListener func(*SocketClient, string, interface{})
+
+// Unsubscribe removes a registered listener, calling it more than once has no effect
+type Unsubscribe func()
 
 type Listenable interface {
 	Listen(string, chan<- *listenerEvent)
 }
 
-type registerListener struct {
+type listenerEntry struct {
+	id 					uint64
 	listenerType 		listenerType
 	event        		string
+	// flag indicating that the listener is removed after the first call
+	once 				bool
+	// set when a one-shot listener was called
+	fired 				int32
 	EventListener
+	ArgsListener
 	ConnectListener
 	DisconnectListener
+	AnyListener
 }
 
 type listenerType int
@@ -22,6 +40,9 @@ const(
 	connectListener listenerType = iota
 	disconnectListener
 	eventListener
+	patternListener
+	anyListener
+	unhandledListener
 )
 
 type listenerEvent struct {
@@ -37,46 +58,264 @@ type listenerEvent struct {
 	ack          *Ack
 	// event data
 	data         interface{}
+	// positional event arguments
+	args         []interface{}
+	// propagated trace context
+	trace 		 map[string]string
 }
 
+// Listeners are safe to modify from any goroutine including running listeners,
+// slices are copied on write so dispatching works on a snapshot without holding the lock
 type Listeners struct {
-	// registration channel
-	liregc 			chan registerListener
-	// connect event channel
-	clientCon		[]ConnectListener
-	// disconnect event channel
-	clientDis 		[]DisconnectListener
+	// lock
+	mtx 			*sync.RWMutex
+	// last assigned listener id
+	lastId 			uint64
+	// connect listeners
+	clientCon		[]*listenerEntry
+	// disconnect listeners
+	clientDis 		[]*listenerEntry
 	// event listeners
-	events 			map[string] []EventListener
+	events 			map[string] []*listenerEntry
+	// event listeners registered with a wildcard pattern
+	patterns 		[]*listenerEntry
+	// listeners receiving all events
+	any 			[]*listenerEntry
+	// listeners receiving events without an event or pattern listener
+	unhandled 		[]*listenerEntry
+	// logger
+	logger 			Logger
 }
 
-func newListeners() *Listeners {
+func newListeners(logger Logger) *Listeners {
 	return &Listeners{
-		liregc:     make(chan registerListener),
-		clientCon:  make([]ConnectListener, 0),
-		clientDis:	make([]DisconnectListener, 0),
-		events: 	make(map[string] []EventListener),
+		mtx: 		new(sync.RWMutex),
+		clientCon:  make([]*listenerEntry, 0),
+		clientDis:	make([]*listenerEntry, 0),
+		events: 	make(map[string] []*listenerEntry),
+		patterns: 	make([]*listenerEntry, 0),
+		any: 		make([]*listenerEntry, 0),
+		unhandled: 	make([]*listenerEntry, 0),
+		logger: 	logger,
 	}
 }
 
-func (lst *Listeners) AddConnectListener(listener ConnectListener) {
-	lst.liregc <- registerListener{
+func (lst *Listeners) AddConnectListener(listener ConnectListener) Unsubscribe {
+	return lst.add(&listenerEntry{
 		listenerType: connectListener,
 		ConnectListener: listener,
-	}
+	})
 }
 
-func (lst *Listeners) AddDisconnectListener(listener DisconnectListener) {
-	lst.liregc <- registerListener{
+func (lst *Listeners) AddDisconnectListener(listener DisconnectListener) Unsubscribe {
+	return lst.add(&listenerEntry{
 		listenerType: disconnectListener,
 		DisconnectListener: listener,
+	})
+}
+
+// Listen registers a listener for the event, events containing *, ? or [
+// are treated as path.Match patterns, e.g. "chat:*"
+func (lst *Listeners) Listen(event string, listener EventListener) Unsubscribe {
+	return lst.add(newEventEntry(event, listener, false))
+}
+
+// Once registers a listener which is removed after it was called for the first time
+func (lst *Listeners) Once(event string, listener EventListener) Unsubscribe {
+	return lst.add(newEventEntry(event, listener, true))
+}
+
+// OnAny registers a listener receiving every event of the namespace
+func (lst *Listeners) OnAny(listener AnyListener) Unsubscribe {
+	return lst.add(&listenerEntry{
+		listenerType: anyListener,
+		AnyListener: listener,
+	})
+}
+
+// OnUnhandled registers a listener receiving events which have
+// no event or pattern listener
+func (lst *Listeners) OnUnhandled(listener AnyListener) Unsubscribe {
+	return lst.add(&listenerEntry{
+		listenerType: unhandledListener,
+		AnyListener: listener,
+	})
+}
+
+// RemoveAllListeners removes the listeners registered for the event or pattern
+func (lst *Listeners) RemoveAllListeners(event string) {
+	lst.mtx.Lock()
+	defer lst.mtx.Unlock()
+	if isEventPattern(event) {
+		patterns := make([]*listenerEntry, 0, len(lst.patterns))
+		for _, entry := range lst.patterns {
+			if entry.event != event {
+				patterns = append(patterns, entry)
+			}
+		}
+		lst.patterns = patterns
+	} else {
+		delete(lst.events, event)
 	}
+	lst.logger.WithFields(Fields{EventField: event}).Debugf("Removed all event listeners")
 }
 
-func (lst *Listeners) Listen(event string, listener EventListener) {
-	lst.liregc <- registerListener{
-		listenerType: eventListener,
-		event: event,
-		EventListener: listener,
+// ListenerCount returns the number of listeners registered for the event or pattern
+func (lst *Listeners) ListenerCount(event string) int {
+	lst.mtx.RLock()
+	defer lst.mtx.RUnlock()
+	if !isEventPattern(event) {
+		return len(lst.events[event])
 	}
-}
\ No newline at end of file
+	count := 0
+	for _, entry := range lst.patterns {
+		if entry.event == event {
+			count++
+		}
+	}
+	return count
+}
+
+func newEventEntry(event string, listener EventListener, once bool) *listenerEntry {
+	lType := eventListener
+	if isEventPattern(event) {
+		lType = patternListener
+	}
+	return &listenerEntry{
+		listenerType: 	lType,
+		event: 			event,
+		once: 			once,
+		EventListener: 	listener,
+	}
+}
+
+func isEventPattern(event string) bool {
+	return strings.ContainsAny(event, "*?[")
+}
+
+func (lst *Listeners) add(entry *listenerEntry) Unsubscribe {
+	lst.mtx.Lock()
+	lst.lastId++
+	entry.id = lst.lastId
+	switch entry.listenerType {
+		case connectListener:
+			lst.clientCon = append(lst.clientCon, entry)
+		case disconnectListener:
+			lst.clientDis = append(lst.clientDis, entry)
+		case eventListener:
+			lst.events[entry.event] = append(lst.events[entry.event], entry)
+		case patternListener:
+			lst.patterns = append(lst.patterns, entry)
+		case anyListener:
+			lst.any = append(lst.any, entry)
+		case unhandledListener:
+			lst.unhandled = append(lst.unhandled, entry)
+	}
+	lst.mtx.Unlock()
+	lst.logger.WithFields(Fields{EventField: entry.event}).Debugf("Registered listener")
+
+	return func() {
+		lst.remove(entry)
+	}
+}
+
+func (lst *Listeners) remove(entry *listenerEntry) {
+	lst.mtx.Lock()
+	defer lst.mtx.Unlock()
+	switch entry.listenerType {
+		case connectListener:
+			lst.clientCon = withoutEntry(lst.clientCon, entry)
+		case disconnectListener:
+			lst.clientDis = withoutEntry(lst.clientDis, entry)
+		case eventListener:
+			if entries := withoutEntry(lst.events[entry.event], entry); len(entries) > 0 {
+				lst.events[entry.event] = entries
+			} else {
+				delete(lst.events, entry.event)
+			}
+		case patternListener:
+			lst.patterns = withoutEntry(lst.patterns, entry)
+		case anyListener:
+			lst.any = withoutEntry(lst.any, entry)
+		case unhandledListener:
+			lst.unhandled = withoutEntry(lst.unhandled, entry)
+	}
+}
+
+// returns a new slice without the entry, the original is left untouched for running dispatches
+func withoutEntry(entries []*listenerEntry, entry *listenerEntry) []*listenerEntry {
+	result := make([]*listenerEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.id != entry.id {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// claims a one-shot listener, returns false if it already fired
+func (lst *Listeners) claim(entry *listenerEntry) bool {
+	if !entry.once {
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&entry.fired, 0, 1) {
+		return false
+	}
+	lst.remove(entry)
+	return true
+}
+
+func (entry *listenerEntry) call(client *SocketClient, data interface{}, args []interface{}) {
+	if entry.ArgsListener != nil {
+		entry.ArgsListener(client, args)
+	} else {
+		entry.EventListener(client, data)
+	}
+}
+
+func (lst *Listeners) onConnect(client *SocketClient) {
+	lst.mtx.RLock()
+	entries := lst.clientCon
+	lst.mtx.RUnlock()
+	for _, entry := range entries {
+		entry.ConnectListener(client)
+	}
+}
+
+func (lst *Listeners) onDisconnect(client *SocketClient) {
+	lst.mtx.RLock()
+	entries := lst.clientDis
+	lst.mtx.RUnlock()
+	for _, entry := range entries {
+		entry.DisconnectListener(client)
+	}
+}
+
+// calls the listeners matching the event
+func (lst *Listeners) dispatch(client *SocketClient, event string, data interface{}, args []interface{}) {
+	lst.mtx.RLock()
+	events, patterns, any, unhandled := lst.events[event], lst.patterns, lst.any, lst.unhandled
+	lst.mtx.RUnlock()
+
+	handled := false
+	for _, entry := range events {
+		if lst.claim(entry) {
+			entry.call(client, data, args)
+			handled = true
+		}
+	}
+	for _, entry := range patterns {
+		if matched, _ := path.Match(entry.event, event); matched && lst.claim(entry) {
+			entry.call(client, data, args)
+			handled = true
+		}
+	}
+	for _, entry := range any {
+		entry.AnyListener(client, event, data)
+	}
+	if !handled {
+		for _, entry := range unhandled {
+			entry.AnyListener(client, event, data)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package socket
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// runs the function from n goroutines released at the same time
+func concurrently(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			f(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestListenersModifiedDuringDispatch(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	var ticks, nested int32
+	lst.Listen("tick", func(client *SocketClient, data interface{}) {
+		atomic.AddInt32(&ticks, 1)
+		// registers and removes listeners while other goroutines dispatch
+		unsubscribe := lst.Listen("tick", func(*SocketClient, interface{}) {
+			atomic.AddInt32(&nested, 1)
+		})
+		lst.OnAny(func(*SocketClient, string, interface{}) {})()
+		lst.Once("tock", func(*SocketClient, interface{}) {})
+		unsubscribe()
+	})
+	lst.Listen("tick:*", func(*SocketClient, interface{}) {
+		lst.RemoveAllListeners("tock")
+	})
+
+	concurrently(20, func(i int) {
+		for j := 0; j < 50; j++ {
+			lst.dispatch(nil, "tick", j, nil)
+			lst.dispatch(nil, "tick:extra", j, nil)
+			lst.dispatch(nil, "tock", j, nil)
+		}
+	})
+
+	if ticks != 20 * 50 {
+		t.Fatalf("Expected %d calls, got %d", 20 * 50, ticks)
+	}
+	if count := lst.ListenerCount("tick"); count != 1 {
+		t.Fatalf("Expected the nested listeners to be removed, got %d listeners", count)
+	}
+	lst.mtx.RLock()
+	defer lst.mtx.RUnlock()
+	if len(lst.any) != 0 {
+		t.Fatalf("Expected no any listeners, got %d", len(lst.any))
+	}
+}
+
+func TestOnceUnderConcurrentEvents(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	var calls, patternCalls int32
+	lst.Once("ping", func(*SocketClient, interface{}) {
+		atomic.AddInt32(&calls, 1)
+	})
+	lst.Once("pi*", func(*SocketClient, interface{}) {
+		atomic.AddInt32(&patternCalls, 1)
+	})
+
+	concurrently(50, func(i int) {
+		lst.dispatch(nil, "ping", i, nil)
+	})
+
+	if calls != 1 || patternCalls != 1 {
+		t.Fatalf("Expected a single call of each listener, got %d and %d", calls, patternCalls)
+	}
+	if lst.ListenerCount("ping") != 0 || lst.ListenerCount("pi*") != 0 {
+		t.Fatal("Expected the once listeners to be removed")
+	}
+}
+
+func TestOnceUnsubscribeBeforeEvent(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	called := false
+	lst.Once("ping", func(*SocketClient, interface{}) {
+		called = true
+	})()
+	lst.dispatch(nil, "ping", nil, nil)
+	if called {
+		t.Fatal("Expected the removed listener not to be called")
+	}
+}
+
+func TestPatternListeners(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	received := make([]string, 0)
+	lst.Listen("chat:*", func(client *SocketClient, data interface{}) {
+		received = append(received, "chat:* " + data.(string))
+	})
+	lst.Listen("room-?", func(client *SocketClient, data interface{}) {
+		received = append(received, "room-? " + data.(string))
+	})
+	lst.Listen("chat:message", func(client *SocketClient, data interface{}) {
+		received = append(received, "chat:message " + data.(string))
+	})
+
+	lst.dispatch(nil, "chat:message", "a", nil)
+	lst.dispatch(nil, "chat:join", "b", nil)
+	lst.dispatch(nil, "room-1", "c", nil)
+	lst.dispatch(nil, "room-10", "d", nil)
+	lst.dispatch(nil, "chat", "e", nil)
+
+	expected := []string{"chat:message a", "chat:* a", "chat:* b", "room-? c"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Fatalf("Expected %v, got %v", expected, received)
+	}
+	if lst.ListenerCount("chat:*") != 1 || lst.ListenerCount("chat:message") != 1 {
+		t.Fatal("Expected patterns and events to be counted separately")
+	}
+	lst.RemoveAllListeners("chat:*")
+	if lst.ListenerCount("chat:*") != 0 || lst.ListenerCount("room-?") != 1 {
+		t.Fatal("Expected only the removed pattern to be gone")
+	}
+}
+
+func TestAnyAndUnhandledListeners(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	anyEvents := make([]string, 0)
+	unhandled := make([]string, 0)
+	lst.Listen("known", func(*SocketClient, interface{}) {})
+	lst.Listen("pattern:*", func(*SocketClient, interface{}) {})
+	removeAny := lst.OnAny(func(client *SocketClient, event string, data interface{}) {
+		anyEvents = append(anyEvents, event)
+	})
+	lst.OnUnhandled(func(client *SocketClient, event string, data interface{}) {
+		unhandled = append(unhandled, event + "=" + data.(string))
+	})
+
+	lst.dispatch(nil, "known", "1", nil)
+	lst.dispatch(nil, "pattern:x", "2", nil)
+	lst.dispatch(nil, "unknown", "3", nil)
+	removeAny()
+	lst.dispatch(nil, "other", "4", nil)
+
+	if !reflect.DeepEqual(anyEvents, []string{"known", "pattern:x", "unknown"}) {
+		t.Fatalf("Unexpected any events %v", anyEvents)
+	}
+	if !reflect.DeepEqual(unhandled, []string{"unknown=3", "other=4"}) {
+		t.Fatalf("Unexpected unhandled events %v", unhandled)
+	}
+}
+
+func TestFiredOnceListenerLeavesEventUnhandled(t *testing.T) {
+	lst := newListeners(NoopLogger())
+	unhandled := 0
+	lst.Once("ping", func(*SocketClient, interface{}) {})
+	lst.OnUnhandled(func(*SocketClient, string, interface{}) {
+		unhandled++
+	})
+
+	lst.dispatch(nil, "ping", nil, nil)
+	lst.dispatch(nil, "ping", nil, nil)
+	if unhandled != 1 {
+		t.Fatalf("Expected the second event to be unhandled, got %d", unhandled)
+	}
+}
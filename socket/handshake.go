@@ -0,0 +1,80 @@
+package socket
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handshake holds the data of the http request which opened the connection
+type Handshake struct {
+	// time of the connection
+	Time 			time.Time
+	// remote address of the client
+	Address 		string
+	// request url
+	URL 			string
+	// request headers
+	Headers 		http.Header
+	// parsed query string of the request
+	Query 			url.Values
+	// flag indicating that the connection was made over TLS
+	Secure 			bool
+	// TLS state, nil for plain connections
+	TLS 			*tls.ConnectionState
+	// query strings sent with the namespace connect packets
+	namespaceQuery 	map[string]url.Values
+	// lock
+	mtx 			*sync.RWMutex
+}
+
+func newHandshake(r *http.Request, trustForwardedProto bool) *Handshake {
+	headers := make(http.Header, len(r.Header))
+	for key, values := range r.Header {
+		headers[key] = append([]string(nil), values...)
+	}
+	return &Handshake{
+		Time: 			time.Now(),
+		Address: 		r.RemoteAddr,
+		URL: 			r.URL.String(),
+		Headers: 		headers,
+		Query: 			r.URL.Query(),
+		Secure: 		isSecureRequest(r, trustForwardedProto),
+		TLS: 			r.TLS,
+		namespaceQuery: make(map[string]url.Values),
+		mtx: 			new(sync.RWMutex),
+	}
+}
+
+func (handshake *Handshake) Cookies() []*http.Cookie {
+	request := http.Request{Header: handshake.Headers}
+	return request.Cookies()
+}
+
+func (handshake *Handshake) Cookie(name string) (*http.Cookie, error) {
+	request := http.Request{Header: handshake.Headers}
+	return request.Cookie(name)
+}
+
+// NamespaceQuery returns the query string sent when connecting to the namespace
+func (handshake *Handshake) NamespaceQuery(namespaceName string) url.Values {
+	handshake.mtx.RLock()
+	defer handshake.mtx.RUnlock()
+	if query, ok := handshake.namespaceQuery[namespaceName]; ok {
+		return query
+	}
+	return url.Values{}
+}
+
+func (handshake *Handshake) setNamespaceQuery(namespaceName string, qs string) {
+	query, err := url.ParseQuery(strings.TrimPrefix(qs, "?"))
+	if err != nil {
+		query = url.Values{}
+	}
+	handshake.mtx.Lock()
+	handshake.namespaceQuery[namespaceName] = query
+	handshake.mtx.Unlock()
+}
@@ -0,0 +1,91 @@
+package socket
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func TestHandshakeFields(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://gse.io/socket?token=abc&tag=a&tag=b", nil)
+	r.RemoteAddr = "10.0.0.1:4000"
+	r.Header.Set("User-Agent", "test")
+	r.Header.Set("Cookie", "session=s1; theme=dark")
+	before := time.Now()
+	handshake := newHandshake(r, false)
+
+	if handshake.Time.Before(before) || handshake.Address != "10.0.0.1:4000" || handshake.URL != "http://gse.io/socket?token=abc&tag=a&tag=b" {
+		t.Fatalf("Unexpected request fields %+v", handshake)
+	}
+	if handshake.Query.Get("token") != "abc" || len(handshake.Query["tag"]) != 2 {
+		t.Fatalf("Unexpected query %v", handshake.Query)
+	}
+	if handshake.Headers.Get("User-Agent") != "test" {
+		t.Fatalf("Unexpected headers %v", handshake.Headers)
+	}
+	if cookie, err := handshake.Cookie("theme"); err != nil || cookie.Value != "dark" {
+		t.Fatalf("Expected the theme cookie, got %v %v", cookie, err)
+	}
+	if len(handshake.Cookies()) != 2 {
+		t.Fatalf("Expected 2 cookies, got %v", handshake.Cookies())
+	}
+	if _, err := handshake.Cookie("missing"); err == nil {
+		t.Fatal("Expected an error for a missing cookie")
+	}
+	if handshake.Secure || handshake.TLS != nil {
+		t.Fatal("Expected a plain connection")
+	}
+
+	// the headers are copied so later changes of the request don't leak in
+	r.Header.Set("User-Agent", "changed")
+	if handshake.Headers.Get("User-Agent") != "test" {
+		t.Fatal("Expected the headers to be copied")
+	}
+}
+
+func TestHandshakeSecure(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.TLS = &tls.ConnectionState{ServerName: "gse.io"}
+	if handshake := newHandshake(r, false); !handshake.Secure || handshake.TLS.ServerName != "gse.io" {
+		t.Fatal("Expected a TLS connection")
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Proto", "HTTPS")
+	if newHandshake(r, false).Secure {
+		t.Fatal("Expected the forwarded protocol to be ignored unless trusted")
+	}
+	if !newHandshake(r, true).Secure {
+		t.Fatal("Expected the trusted forwarded protocol to be used")
+	}
+}
+
+func TestHandshakeNamespaceQuery(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	if _, err := server.AddNamespace("/chat", nil); err != nil {
+		t.Fatal(err)
+	}
+	client := openTestClient(t, server, "/?token=abc")
+	err := server.addNamespaceClient(client, &transport.Packet{
+		PacketType: transport.Connect,
+		Endpoint: 	"/chat",
+		Qs: 		"?room=lobby",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshake := client.Handshake()
+	if handshake.Query.Get("token") != "abc" {
+		t.Fatalf("Expected the query of the request, got %v", handshake.Query)
+	}
+	if room := handshake.NamespaceQuery("/chat").Get("room"); room != "lobby" {
+		t.Fatalf("Expected the namespace query, got %q", room)
+	}
+	if query := handshake.NamespaceQuery("/other"); len(query) != 0 {
+		t.Fatalf("Expected an empty query for another namespace, got %v", query)
+	}
+}
@@ -0,0 +1,58 @@
+package socket
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat tracks the pongs of a client, the server sends a ping every interval
+// and drops the client when no pong arrives within the timeout
+type heartbeat struct {
+	interval 	time.Duration
+	timeout 	time.Duration
+	// time of the last pong in unix nanoseconds
+	lastPong 	int64
+}
+
+// returns nil when the heartbeat is disabled
+func newHeartbeat(conf *ServerConf) *heartbeat {
+	interval, timeout := time.Duration(conf.PingInterval), time.Duration(conf.PingTimeout)
+	if conf.Protocol == SocketIOProtocol {
+		if interval == 0 {
+			interval = PingInterval
+		}
+		if timeout == 0 {
+			timeout = PingTimeout
+		}
+	}
+	if interval <= 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	return &heartbeat{
+		interval: 	interval,
+		timeout: 	timeout,
+		lastPong: 	time.Now().UnixNano(),
+	}
+}
+
+func (hb *heartbeat) pong() {
+	if hb != nil {
+		atomic.StoreInt64(&hb.lastPong, time.Now().UnixNano())
+	}
+}
+
+// reports whether the pong of the last ping is overdue
+func (hb *heartbeat) expired() bool {
+	lastPong := time.Unix(0, atomic.LoadInt64(&hb.lastPong))
+	return time.Since(lastPong) > hb.interval + hb.timeout
+}
+
+func (hb *heartbeat) durations() (time.Duration, time.Duration) {
+	if hb == nil {
+		return 0, 0
+	}
+	return hb.interval, hb.timeout
+}
@@ -0,0 +1,171 @@
+package socket
+
+import (
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// in-memory transport buffering the written frames
+type testTransport struct {
+	in 		chan transport.Frame
+	// written frames, dropped when nobody reads them
+	out 	chan transport.Frame
+	closec 	chan struct{}
+	once 	*sync.Once
+}
+
+func newTestTransport() *testTransport {
+	return &testTransport{
+		in: 	make(chan transport.Frame),
+		out: 	make(chan transport.Frame, 64),
+		closec: make(chan struct{}),
+		once: 	new(sync.Once),
+	}
+}
+
+func (conn *testTransport) Name() string {
+	return "test"
+}
+
+func (conn *testTransport) Read() (transport.Frame, error) {
+	select {
+		case frame := <-conn.in:
+			return frame, nil
+		case <-conn.closec:
+			return transport.Frame{}, io.EOF
+	}
+}
+
+func (conn *testTransport) Write(frames []transport.Frame) error {
+	select {
+		case <-conn.closec:
+			return transport.ErrTransportClosed
+		default:
+	}
+	for _, frame := range frames {
+		select {
+			case conn.out <- frame:
+			default:
+		}
+	}
+	return nil
+}
+
+// sends the packet to the client as if it came from the connection
+func (conn *testTransport) send(t testing.TB, packet *transport.Packet) {
+	data, err := transport.Encode(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+		case conn.in <- transport.Frame{Data: data}:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Client didn't read the packet")
+	}
+}
+
+// waits for the next written packet of the type, packets of other types are skipped
+func (conn *testTransport) expect(t testing.TB, packetType transport.PacketType) *transport.Packet {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+			case frame := <-conn.out:
+				packet, err := transport.Decode(frame.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if packet.PacketType == packetType {
+					return packet
+				}
+			case <-timeout:
+				t.Fatalf("Expected a packet of type %d", packetType)
+				return nil
+		}
+	}
+}
+
+// fails if a packet of the type is written within the duration
+func (conn *testTransport) expectNone(t testing.TB, packetType transport.PacketType, duration time.Duration) {
+	timeout := time.After(duration)
+	for {
+		select {
+			case frame := <-conn.out:
+				if packet, err := transport.Decode(frame.Data); err == nil && packet.PacketType == packetType {
+					t.Fatalf("Unexpected packet %+v", packet)
+				}
+			case <-timeout:
+				return
+		}
+	}
+}
+
+func (conn *testTransport) Close() error {
+	conn.once.Do(func() {
+		close(conn.closec)
+	})
+	return nil
+}
+
+func newTestServer(t testing.TB, conf *ServerConf) *Server {
+	if conf == nil {
+		conf = DefaultConf()
+	}
+	server := NewServer(nil, conf)
+	server.Run()
+	return server
+}
+
+func openTestClient(t testing.TB, server *Server, target string) *Client {
+	client, _ := openTestConn(t, server, target)
+	return client
+}
+
+// opens a client and returns its transport to exchange packets with
+func openTestConn(t testing.TB, server *Server, target string) (*Client, *testTransport) {
+	conn := newTestTransport()
+	client, err := server.ServeTransport(conn, httptest.NewRequest("GET", target, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, conn
+}
+
+// connects the client to the namespace as a connect packet would
+func connectTestClient(t testing.TB, client *Client, namespaceName string) *SocketClient {
+	err := client.server.addNamespaceClient(client, &transport.Packet{
+		PacketType: transport.Connect,
+		Endpoint: 	namespaceName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.wrap(client.getNamespace(namespaceName))
+}
+
+// checks that the client and room indexes of the namespace agree
+func assertRoomIndexes(t testing.TB, namespace *Namespace) {
+	for _, room := range namespace.GetRooms() {
+		for _, client := range room.GetClients() {
+			client.mtx.RLock()
+			_, ok := client.rooms[room.uuid]
+			client.mtx.RUnlock()
+			if !ok {
+				t.Errorf("Client %s is in room %s without indexing it", client.uuid, room.name)
+			}
+		}
+	}
+	for _, client := range namespace.GetClients() {
+		for _, room := range client.GetAllRooms() {
+			if !room.HasClient(client.uuid) {
+				t.Errorf("Client %s indexes room %s without being in it", client.uuid, room.name)
+			}
+			if existing, err := namespace.GetRoomById(room.uuid); err != nil || existing != room {
+				t.Errorf("Client %s indexes removed room %s", client.uuid, room.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+var errHistoryDisabled = errors.New("Room doesn't keep history")
+
+// HistoryMessage is an event recorded by a room
+type HistoryMessage struct {
+	// id assigned by the store, increasing within the room
+	Id 		int64 			`json:"id"`
+	// unix time in milliseconds at which the event was sent
+	Time 	int64 			`json:"time"`
+	// event name
+	Name 	string 			`json:"name"`
+	// event data
+	Data 	interface{} 	`json:"data,omitempty"`
+	// positional event arguments
+	Args 	[]interface{} 	`json:"args,omitempty"`
+}
+
+// HistoryQuery selects the recorded messages, zero fields don't restrict the result
+type HistoryQuery struct {
+	// only messages with a greater id
+	AfterId 	int64 	`json:"afterId,omitempty"`
+	// only messages sent at or after the unix time in milliseconds
+	Since 		int64 	`json:"since,omitempty"`
+	// maximum number of the newest matching messages
+	Limit 		int 	`json:"limit,omitempty"`
+}
+
+func (query HistoryQuery) matches(message *HistoryMessage) bool {
+	return message.Id > query.AfterId && message.Time >= query.Since
+}
+
+// HistoryStore keeps the messages of a single room, it must be safe for concurrent use.
+// Stores implementing io.Closer are closed when the room is destroyed or its history replaced
+type HistoryStore interface {
+	// Append assigns the message an id greater than the ids of the stored messages and stores it
+	Append(message *HistoryMessage) error
+	// Since returns the messages matching the query, oldest first
+	Since(query HistoryQuery) ([]*HistoryMessage, error)
+}
+
+// HistoryStoreFactory creates the history store of a room
+type HistoryStoreFactory func(namespace string, room string, conf *HistoryConf) HistoryStore
+
+type HistoryConf struct {
+	// maximum number of kept messages, zero means unlimited
+	Size 	int 					`json:"size"`
+	// duration for which the messages are kept, zero means forever
+	MaxAge 	Duration 				`json:"maxAge"`
+	// storage of the messages, nil keeps them in memory
+	Store 	HistoryStoreFactory 	`json:"-"`
+}
+
+func validateHistory(confErr *ConfError, field string, conf *HistoryConf) {
+	if conf == nil {
+		return
+	}
+	if conf.Size < 0 {
+		confErr.add("%s.size must not be negative, got %d", field, conf.Size)
+	}
+	if conf.MaxAge < 0 {
+		confErr.add("%s.maxAge must not be negative, got %s", field, time.Duration(conf.MaxAge))
+	}
+	if conf.Store == nil && conf.Size == 0 && conf.MaxAge == 0 {
+		confErr.add("%s must be bounded by size or maxAge when kept in memory", field)
+	}
+}
+
+func (conf *HistoryConf) newStore(namespace string, room string) HistoryStore {
+	if conf.Store != nil {
+		return conf.Store(namespace, room, conf)
+	}
+	return NewMemoryHistory(conf)
+}
+
+// MemoryHistory is a history store bounded by the size and the age of the messages
+type MemoryHistory struct {
+	size 		int
+	maxAge 		time.Duration
+	// kept messages are messages[head:], dropped ones are compacted away once they make up half of the slice
+	messages 	[]*HistoryMessage
+	head 		int
+	lastId 		int64
+	mtx 		*sync.Mutex
+}
+
+func NewMemoryHistory(conf *HistoryConf) *MemoryHistory {
+	return &MemoryHistory{
+		size: 		conf.Size,
+		maxAge: 	time.Duration(conf.MaxAge),
+		messages: 	make([]*HistoryMessage, 0),
+		mtx: 		new(sync.Mutex),
+	}
+}
+
+func (history *MemoryHistory) Append(message *HistoryMessage) error {
+	history.mtx.Lock()
+	defer history.mtx.Unlock()
+	history.lastId++
+	message.Id = history.lastId
+	history.messages = append(history.messages, message)
+	if history.size > 0 && len(history.messages) - history.head > history.size {
+		history.drop(len(history.messages) - history.head - history.size)
+	}
+	history.expire()
+	return nil
+}
+
+func (history *MemoryHistory) Since(query HistoryQuery) ([]*HistoryMessage, error) {
+	history.mtx.Lock()
+	defer history.mtx.Unlock()
+	history.expire()
+	messages := make([]*HistoryMessage, 0)
+	for _, message := range history.messages[history.head:] {
+		if query.matches(message) {
+			messages = append(messages, message)
+		}
+	}
+	if query.Limit > 0 && len(messages) > query.Limit {
+		messages = messages[len(messages) - query.Limit:]
+	}
+	return messages, nil
+}
+
+// drops the messages older than the maximum age, must be called with the lock held
+func (history *MemoryHistory) expire() {
+	if history.maxAge == 0 {
+		return
+	}
+	oldest := unixMillis(time.Now().Add(-history.maxAge))
+	n := 0
+	for history.head + n < len(history.messages) && history.messages[history.head + n].Time < oldest {
+		n++
+	}
+	history.drop(n)
+}
+
+// drops the n oldest messages, the slice is compacted when the dropped messages
+// make up half of it so appending stays amortized constant time
+func (history *MemoryHistory) drop(n int) {
+	if n == 0 {
+		return
+	}
+	for i := history.head; i < history.head + n; i++ {
+		history.messages[i] = nil
+	}
+	history.head += n
+	if history.head == len(history.messages) {
+		history.messages = history.messages[:0]
+		history.head = 0
+	} else if history.head >= len(history.messages) / 2 {
+		history.messages = append(make([]*HistoryMessage, 0, 2 * (len(history.messages) - history.head)), history.messages[history.head:]...)
+		history.head = 0
+	}
+}
+
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// records the event if the room keeps history, the packet is stamped with the message id
+func (room *Room) record(packet *transport.Packet) {
+	history := room.getHistory()
+	if history == nil {
+		// only events recorded in a history carry a seq
+		packet.Seq = 0
+		return
+	}
+	message := &HistoryMessage{
+		Time: 	unixMillis(time.Now()),
+		Name: 	packet.Name,
+		Data: 	packet.Data,
+		Args: 	packet.Args,
+	}
+	if err := history.Append(message); err != nil {
+		room.namespace.logger.WithFields(Fields{RoomField: room.name, EventField: packet.Name}).Warnf("Failed to record history: %v", err)
+		return
+	}
+	packet.Seq = message.Id
+}
+
+func (room *Room) getHistory() HistoryStore {
+	room.mtx.RLock()
+	defer room.mtx.RUnlock()
+	return room.history
+}
+
+// EnableHistory starts recording the events sent to the room, nil configuration stops it
+func (room *Room) EnableHistory(conf *HistoryConf) error {
+	var history HistoryStore
+	if conf != nil {
+		confErr := &ConfError{}
+		validateHistory(confErr, "history", conf)
+		if len(confErr.Problems) > 0 {
+			return confErr
+		}
+		history = conf.newStore(room.namespace.name, room.name)
+	}
+	room.mtx.Lock()
+	previous := room.history
+	room.history = history
+	room.mtx.Unlock()
+	closeHistory(previous)
+	return nil
+}
+
+// releases the resources of the store if it implements io.Closer
+func closeHistory(history HistoryStore) {
+	if closer, ok := history.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// History returns the recorded messages matching the query
+func (room *Room) History(query HistoryQuery) ([]*HistoryMessage, error) {
+	history := room.getHistory()
+	if history == nil {
+		return nil, errHistoryDisabled
+	}
+	return history.Since(query)
+}
+
+// the client asks for the history of a room it is in, the packet name is the room
+// and the data the query, the messages are sent back in a history packet with the same id
+func (client *Client) onHistory(packet *transport.Packet) error {
+	namespace, err := client.on(packet)
+	if err != nil {
+		return err
+	}
+	var query HistoryQuery
+	if packet.Data != nil {
+		raw, err := json.Marshal(packet.Data)
+		if err == nil {
+			err = json.Unmarshal(raw, &query)
+		}
+		if err != nil {
+			client.sendError(namespace.name, makeComplexError(FailedToParsePacket, err))
+			return err
+		}
+	}
+	room, err := namespace.GetRoom(packet.Name)
+	if err != nil || !room.HasClient(client.uuid) {
+		client.sendError(namespace.name, makeError(RoomDoesNotExist))
+		return errors.New("Client is not in the room")
+	}
+	if err := client.sendHistory(room, packet.Id, query); err != nil {
+		client.sendError(namespace.name, makeComplexError(HistoryUnavailable, err))
+		return err
+	}
+	return nil
+}
+
+func (client *Client) sendHistory(room *Room, id int64, query HistoryQuery) error {
+	messages, err := room.History(query)
+	if err != nil {
+		return err
+	}
+	client.sendPacket(room.namespace, &transport.Packet{
+		PacketType: transport.History,
+		Endpoint: 	room.namespace.name,
+		Name: 		room.name,
+		Id: 		id,
+		Data: 		messages,
+	})
+	return nil
+}
+
+// JoinRoomSince joins the room and sends the client the recorded messages matching the query,
+// messages sent while joining can be received twice and are recognized by their seq
+func (client *SocketClient) JoinRoomSince(roomName string, query HistoryQuery) error {
+	if err := client.JoinRoom(roomName); err != nil {
+		return err
+	}
+	room, err := client.namespace.GetRoom(roomName)
+	if err != nil {
+		return err
+	}
+	return client.Client.sendHistory(room, 0, query)
+}
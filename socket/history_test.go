@@ -0,0 +1,132 @@
+package socket
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func historyIds(t *testing.T, history HistoryStore, query HistoryQuery) []int64 {
+	messages, err := history.Since(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, len(messages))
+	for i, message := range messages {
+		ids[i] = message.Id
+	}
+	return ids
+}
+
+func TestMemoryHistorySize(t *testing.T) {
+	history := NewMemoryHistory(&HistoryConf{Size: 3})
+	for i := 0; i < 1000; i++ {
+		history.Append(&HistoryMessage{Time: unixMillis(time.Now())})
+		if kept := len(history.messages) - history.head; kept > 3 {
+			t.Fatalf("Expected at most 3 messages, kept %d", kept)
+		}
+		if cap(history.messages) > 8 {
+			t.Fatalf("Expected the dropped messages to be released, capacity %d", cap(history.messages))
+		}
+	}
+
+	if ids := historyIds(t, history, HistoryQuery{}); len(ids) != 3 || ids[0] != 998 || ids[2] != 1000 {
+		t.Fatalf("Expected the newest 3 messages, got %v", ids)
+	}
+	if ids := historyIds(t, history, HistoryQuery{AfterId: 998}); len(ids) != 2 || ids[0] != 999 {
+		t.Fatalf("Expected the messages after 998, got %v", ids)
+	}
+	if ids := historyIds(t, history, HistoryQuery{Limit: 1}); len(ids) != 1 || ids[0] != 1000 {
+		t.Fatalf("Expected the newest message, got %v", ids)
+	}
+}
+
+func TestMemoryHistoryMaxAge(t *testing.T) {
+	history := NewMemoryHistory(&HistoryConf{MaxAge: Duration(time.Minute)})
+	old := unixMillis(time.Now().Add(-time.Hour))
+	for i := 0; i < 5; i++ {
+		history.Append(&HistoryMessage{Time: old})
+	}
+	history.Append(&HistoryMessage{Time: unixMillis(time.Now())})
+
+	if ids := historyIds(t, history, HistoryQuery{}); len(ids) != 1 || ids[0] != 6 {
+		t.Fatalf("Expected only the recent message, got %v", ids)
+	}
+	if history.head != 0 || len(history.messages) != 1 {
+		t.Fatalf("Expected the expired messages to be compacted, head %d of %d", history.head, len(history.messages))
+	}
+}
+
+type closingHistory struct {
+	*MemoryHistory
+	closed 	*int32
+}
+
+func (history closingHistory) Close() error {
+	atomic.AddInt32(history.closed, 1)
+	return nil
+}
+
+func closingHistoryConf(closed *int32) *HistoryConf {
+	return &HistoryConf{
+		Store: func(namespace string, room string, conf *HistoryConf) HistoryStore {
+			return closingHistory{NewMemoryHistory(&HistoryConf{Size: 10}), closed}
+		},
+	}
+}
+
+func TestHistoryClosedWithRoom(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	room, err := server.AddRoom("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closed int32
+	if err := room.EnableHistory(closingHistoryConf(&closed)); err != nil {
+		t.Fatal(err)
+	}
+	// replacing the history closes the previous store
+	if err := room.EnableHistory(closingHistoryConf(&closed)); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 1 {
+		t.Fatalf("Expected the replaced store to be closed, got %d", closed)
+	}
+
+	server.RemoveRoom("lobby")
+	if closed != 2 {
+		t.Fatalf("Expected the store to be closed with the room, got %d", closed)
+	}
+	if _, err := room.History(HistoryQuery{}); err != errHistoryDisabled {
+		t.Fatalf("Expected %v, got %v", errHistoryDisabled, err)
+	}
+	room.Destroy()
+	if closed != 2 {
+		t.Fatal("Expected the store to be closed once")
+	}
+}
+
+func TestSeqOnlyWithHistory(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	room, err := server.AddRoom("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet := &transport.Packet{PacketType: transport.Event, Name: "message", Seq: 5}
+	room.record(packet)
+	if packet.Seq != 0 {
+		t.Fatalf("Expected no seq without history, got %d", packet.Seq)
+	}
+
+	if err := room.EnableHistory(&HistoryConf{Size: 10}); err != nil {
+		t.Fatal(err)
+	}
+	room.record(packet)
+	if packet.Seq != 1 {
+		t.Fatalf("Expected seq 1, got %d", packet.Seq)
+	}
+}
@@ -0,0 +1,152 @@
+package socket
+
+import (
+	"time"
+)
+
+type LimitPolicy string
+
+const(
+	// silently drop the packet
+	DropPolicy 			LimitPolicy = "drop"
+	// drop the packet and notify the client with an error packet
+	ErrorPolicy 		LimitPolicy = "error"
+	// disconnect the client
+	DisconnectPolicy 	LimitPolicy = "disconnect"
+)
+
+type EventLimitConf struct {
+	// number of events per second
+	EventsPerSecond 	float64		`json:"eventsPerSecond"`
+	// maximum burst of events
+	EventBurst 			int			`json:"eventBurst"`
+}
+
+type RateLimitConf struct {
+	// number of inbound packets per second, zero means unlimited
+	PacketsPerSecond 	float64						`json:"packetsPerSecond"`
+	// maximum burst of packets
+	PacketBurst 		int							`json:"packetBurst"`
+	// number of inbound bytes per second, zero means unlimited
+	BytesPerSecond 		float64						`json:"bytesPerSecond"`
+	// maximum burst of bytes
+	BytesBurst 			int							`json:"bytesBurst"`
+	// limits per event name
+	Events 				map[string]EventLimitConf	`json:"events"`
+	// what to do with throttled packets
+	Policy 				LimitPolicy					`json:"policy"`
+}
+
+type tokenBucket struct {
+	// tokens added per second
+	rate   		float64
+	// bucket capacity
+	burst  		float64
+	// currently available tokens
+	tokens 		float64
+	// time of the last refill
+	last   		time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	capacity := float64(burst)
+	if capacity <= 0 {
+		capacity = rate
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tokenBucket{
+		rate: 		rate,
+		burst: 		capacity,
+		tokens: 	capacity,
+		last: 		time.Now(),
+	}
+}
+
+func (bucket *tokenBucket) allow(now time.Time, n float64) bool {
+	if bucket == nil {
+		return true
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+	bucket.last = now
+	if bucket.tokens < n {
+		return false
+	}
+	bucket.tokens -= n
+	return true
+}
+
+// warning: Thread unsafe, used only from the client read pump
+type clientLimiter struct {
+	conf 		*RateLimitConf
+	// packet bucket
+	packets		*tokenBucket
+	// byte bucket
+	bytes		*tokenBucket
+	// buckets per event name
+	events 		map[string]*tokenBucket
+}
+
+func newClientLimiter(conf *RateLimitConf) *clientLimiter {
+	if conf == nil {
+		return nil
+	}
+	return &clientLimiter{
+		conf: 		conf,
+		packets:	newTokenBucket(conf.PacketsPerSecond, conf.PacketBurst),
+		bytes: 		newTokenBucket(conf.BytesPerSecond, conf.BytesBurst),
+		events: 	make(map[string]*tokenBucket),
+	}
+}
+
+// rebuilds the limiter when the rate limit configuration was reloaded
+func (limiter *clientLimiter) update(conf *RateLimitConf) *clientLimiter {
+	if limiter == nil && conf == nil {
+		return nil
+	}
+	if limiter != nil && limiter.conf == conf {
+		return limiter
+	}
+	return newClientLimiter(conf)
+}
+
+func (limiter *clientLimiter) allowPacket(size int) bool {
+	if limiter == nil {
+		return true
+	}
+	now := time.Now()
+	if !limiter.packets.allow(now, 1) {
+		return false
+	}
+	return limiter.bytes.allow(now, float64(size))
+}
+
+func (limiter *clientLimiter) allowEvent(event string) bool {
+	if limiter == nil {
+		return true
+	}
+	bucket, ok := limiter.events[event]
+	if !ok {
+		eventConf, ok := limiter.conf.Events[event]
+		if !ok {
+			return true
+		}
+		bucket = newTokenBucket(eventConf.EventsPerSecond, eventConf.EventBurst)
+		limiter.events[event] = bucket
+	}
+	return bucket.allow(time.Now(), 1)
+}
+
+func (limiter *clientLimiter) policy() LimitPolicy {
+	if limiter == nil || limiter.conf.Policy == "" {
+		return DropPolicy
+	}
+	return limiter.conf.Policy
+}
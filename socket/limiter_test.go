@@ -0,0 +1,162 @@
+package socket
+
+import (
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(2, 3)
+	now := bucket.last
+	for i := 0; i < 3; i++ {
+		if !bucket.allow(now, 1) {
+			t.Fatalf("Expected token %d of the burst", i)
+		}
+	}
+	if bucket.allow(now, 1) {
+		t.Fatal("Expected the bucket to be empty")
+	}
+	if !bucket.allow(now.Add(500 * time.Millisecond), 1) {
+		t.Fatal("Expected a token after half a second")
+	}
+	// refill is capped by the burst
+	now = now.Add(time.Hour)
+	if !bucket.allow(now, 3) || bucket.allow(now, 1) {
+		t.Fatalf("Expected the refill to stop at the burst, got %v tokens", bucket.tokens)
+	}
+	if bucket.allow(now, 4) {
+		t.Fatal("Expected a request larger than the burst to fail")
+	}
+}
+
+func TestTokenBucketDefaults(t *testing.T) {
+	if bucket := newTokenBucket(0, 10); bucket != nil || !bucket.allow(time.Now(), 100) {
+		t.Fatal("Expected zero rate to be unlimited")
+	}
+	if bucket := newTokenBucket(5, 0); bucket.burst != 5 {
+		t.Fatalf("Expected the burst to default to the rate, got %v", bucket.burst)
+	}
+	if bucket := newTokenBucket(0.5, 0); bucket.burst != 1 {
+		t.Fatalf("Expected at least a burst of one, got %v", bucket.burst)
+	}
+}
+
+func TestClientLimiterEvents(t *testing.T) {
+	limiter := newClientLimiter(&RateLimitConf{
+		Events: map[string]EventLimitConf{"chat": {EventsPerSecond: 1, EventBurst: 1}},
+	})
+	if !limiter.allowPacket(1000) {
+		t.Fatal("Expected unlimited packets")
+	}
+	if !limiter.allowEvent("chat") || limiter.allowEvent("chat") {
+		t.Fatal("Expected a single chat event")
+	}
+	if !limiter.allowEvent("other") || !limiter.allowEvent("other") {
+		t.Fatal("Expected unlimited events without own limits")
+	}
+	if limiter.policy() != DropPolicy {
+		t.Fatalf("Expected the drop policy by default, got %s", limiter.policy())
+	}
+}
+
+func TestClientLimiterUpdate(t *testing.T) {
+	var limiter *clientLimiter
+	if limiter.update(nil) != nil {
+		t.Fatal("Expected no limiter without configuration")
+	}
+	conf := &RateLimitConf{PacketsPerSecond: 1}
+	limiter = limiter.update(conf)
+	if limiter == nil || limiter.conf != conf {
+		t.Fatal("Expected a limiter for the configuration")
+	}
+	if !limiter.allowPacket(1) || limiter.allowPacket(1) {
+		t.Fatal("Expected a single packet")
+	}
+	// the buckets are kept while the configuration doesn't change
+	if updated := limiter.update(conf); updated != limiter {
+		t.Fatal("Expected the same limiter")
+	}
+	reloaded := &RateLimitConf{PacketsPerSecond: 1}
+	if updated := limiter.update(reloaded); updated == limiter || !updated.allowPacket(1) {
+		t.Fatal("Expected a new limiter for the reloaded configuration")
+	}
+	if limiter.update(nil) != nil {
+		t.Fatal("Expected the limiter to be removed")
+	}
+}
+
+func limitedTestConn(t *testing.T, policy LimitPolicy) (*Server, *Client, *testTransport) {
+	conf := DefaultConf()
+	conf.RateLimit = &RateLimitConf{PacketsPerSecond: 0.001, PacketBurst: 1, Policy: policy}
+	server := newTestServer(t, conf)
+	client, conn := openTestConn(t, server, "/")
+	return server, client, conn
+}
+
+func TestDropPolicy(t *testing.T) {
+	server, client, conn := limitedTestConn(t, DropPolicy)
+	defer server.Stop()
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.expect(t, transport.Pong)
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+
+	conn.expectNone(t, transport.Pong, 50 * time.Millisecond)
+	if throttled := server.GetStats().ThrottledPackets; throttled != 1 {
+		t.Fatalf("Expected 1 throttled packet, got %d", throttled)
+	}
+	if !client.isOpen() {
+		t.Fatal("Expected the client to stay open")
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	server, client, conn := limitedTestConn(t, ErrorPolicy)
+	defer server.Stop()
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.expect(t, transport.Pong)
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+
+	packet := conn.expect(t, transport.Error)
+	data, _ := packet.Data.(map[string]interface{})
+	if code, _ := data["errorCode"].(float64); int(code) != RateLimitExceeded {
+		t.Fatalf("Expected the rate limit error, got %v", packet.Data)
+	}
+	if !client.isOpen() {
+		t.Fatal("Expected the client to stay open")
+	}
+}
+
+func TestDisconnectPolicy(t *testing.T) {
+	server, client, conn := limitedTestConn(t, DisconnectPolicy)
+	defer server.Stop()
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+
+	select {
+		case <-conn.closec:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the connection to be closed")
+	}
+	if client.isOpen() {
+		t.Fatal("Expected the client to be closed")
+	}
+}
+
+func TestRateLimitAppliesAfterReload(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	_, conn := openTestConn(t, server, "/")
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.expect(t, transport.Pong)
+
+	conf := DefaultConf()
+	conf.RateLimit = &RateLimitConf{PacketsPerSecond: 0.001, PacketBurst: 1}
+	if err := server.Reload(conf); err != nil {
+		t.Fatal(err)
+	}
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.expect(t, transport.Pong)
+	conn.send(t, &transport.Packet{PacketType: transport.Ping})
+	conn.expectNone(t, transport.Pong, 50 * time.Millisecond)
+}
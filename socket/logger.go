@@ -0,0 +1,86 @@
+package socket
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// names of the structured log fields
+const(
+	ServerField 	= "server"
+	SessionField 	= "sessionId"
+	NamespaceField 	= "namespace"
+	RoomField 		= "room"
+	EventField 		= "event"
+	AddressField 	= "address"
+	TransportField 	= "transport"
+)
+
+type Fields map[string]interface{}
+
+type Logger interface {
+	// returns a logger which adds the fields to every message
+	WithFields(Fields) Logger
+
+	Debugf(string, ...interface{})
+
+	Infof(string, ...interface{})
+
+	Warnf(string, ...interface{})
+
+	Errorf(string, ...interface{})
+}
+
+type noopLogger struct{}
+
+// NoopLogger discards all messages, used when no logger is configured
+func NoopLogger() Logger {
+	return noopLogger{}
+}
+
+func (logger noopLogger) WithFields(Fields) Logger {
+	return logger
+}
+
+func (noopLogger) Debugf(string, ...interface{}) {}
+
+func (noopLogger) Infof(string, ...interface{}) {}
+
+func (noopLogger) Warnf(string, ...interface{}) {}
+
+func (noopLogger) Errorf(string, ...interface{}) {}
+
+type logrusLogger struct {
+	entry 	*logrus.Entry
+}
+
+// NewLogrusLogger adapts a logrus logger, nil uses the standard logrus logger
+func NewLogrusLogger(logger *logrus.Logger) Logger {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &logrusLogger{
+		entry: logrus.NewEntry(logger),
+	}
+}
+
+func (logger *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{
+		entry: logger.entry.WithFields(logrus.Fields(fields)),
+	}
+}
+
+func (logger *logrusLogger) Debugf(format string, args ...interface{}) {
+	logger.entry.Debugf(format, args...)
+}
+
+func (logger *logrusLogger) Infof(format string, args ...interface{}) {
+	logger.entry.Infof(format, args...)
+}
+
+func (logger *logrusLogger) Warnf(format string, args ...interface{}) {
+	logger.entry.Warnf(format, args...)
+}
+
+func (logger *logrusLogger) Errorf(format string, args ...interface{}) {
+	logger.entry.Errorf(format, args...)
+}
@@ -0,0 +1,144 @@
+package socket
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"github.com/sirupsen/logrus"
+)
+
+type loggedEntry struct {
+	level 	string
+	message string
+	fields 	Fields
+}
+
+// logger keeping the messages with their fields
+type recordingLogger struct {
+	fields 	Fields
+	entries *[]loggedEntry
+	mtx 	*sync.Mutex
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{
+		fields: 	Fields{},
+		entries: 	new([]loggedEntry),
+		mtx: 		new(sync.Mutex),
+	}
+}
+
+func (logger *recordingLogger) WithFields(fields Fields) Logger {
+	merged := Fields{}
+	for key, value := range logger.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &recordingLogger{fields: merged, entries: logger.entries, mtx: logger.mtx}
+}
+
+func (logger *recordingLogger) log(level string, format string, args []interface{}) {
+	logger.mtx.Lock()
+	*logger.entries = append(*logger.entries, loggedEntry{level, format, logger.fields})
+	logger.mtx.Unlock()
+}
+
+func (logger *recordingLogger) Debugf(format string, args ...interface{}) {
+	logger.log("debug", format, args)
+}
+
+func (logger *recordingLogger) Infof(format string, args ...interface{}) {
+	logger.log("info", format, args)
+}
+
+func (logger *recordingLogger) Warnf(format string, args ...interface{}) {
+	logger.log("warning", format, args)
+}
+
+func (logger *recordingLogger) Errorf(format string, args ...interface{}) {
+	logger.log("error", format, args)
+}
+
+func (logger *recordingLogger) find(message string) (loggedEntry, bool) {
+	logger.mtx.Lock()
+	defer logger.mtx.Unlock()
+	for _, entry := range *logger.entries {
+		if entry.message == message {
+			return entry, true
+		}
+	}
+	return loggedEntry{}, false
+}
+
+func TestLogrusLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.Out = &buffer
+	logrusLogger.Formatter = &logrus.JSONFormatter{}
+	logrusLogger.Level = logrus.InfoLevel
+
+	logger := NewLogrusLogger(logrusLogger).WithFields(Fields{ServerField: "gse"})
+	logger.Debugf("hidden")
+	logger.WithFields(Fields{SessionField: "sid"}).Infof("opened %d", 1)
+	logger.Warnf("warned")
+	logger.Errorf("failed")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 messages above the debug level, got %q", lines)
+	}
+	expected := []struct {
+		level 	string
+		message string
+		session interface{}
+	}{
+		{"info", "opened 1", "sid"},
+		{"warning", "warned", nil},
+		{"error", "failed", nil},
+	}
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["level"] != expected[i].level || entry["msg"] != expected[i].message {
+			t.Fatalf("Expected %+v, got %v", expected[i], entry)
+		}
+		if entry[ServerField] != "gse" || entry[SessionField] != expected[i].session {
+			t.Fatalf("Unexpected fields %v", entry)
+		}
+	}
+}
+
+func TestNoopLogger(t *testing.T) {
+	logger := NoopLogger()
+	if logger.WithFields(Fields{EventField: "e"}) != logger {
+		t.Fatal("Expected the noop logger to return itself")
+	}
+	logger.Errorf("discarded %v", 1)
+}
+
+func TestServerLogsWithFields(t *testing.T) {
+	logger := newRecordingLogger()
+	conf := DefaultConf()
+	conf.Logger = logger
+	server := newTestServer(t, conf)
+	client := openTestClient(t, server, "/")
+	server.Stop()
+
+	entry, ok := logger.find("Starting server")
+	if !ok || entry.level != "info" || entry.fields[ServerField] != conf.ServerName {
+		t.Fatalf("Expected the start to be logged with the server name, got %+v", entry)
+	}
+	entry, ok = logger.find("Client connection established")
+	if !ok || entry.fields[SessionField] != client.GetSessionId() || entry.fields[TransportField] != "test" {
+		t.Fatalf("Expected the connection to be logged with the session, got %+v", entry)
+	}
+	if entry.fields[ServerField] != conf.ServerName {
+		t.Fatalf("Expected the server field to be inherited, got %+v", entry.fields)
+	}
+}
@@ -0,0 +1,131 @@
+package socket
+
+import (
+	"context"
+	"github.com/ppincak/gse/socket/tracing"
+)
+
+// Middleware intercepts inbound events before they reach the listeners,
+// calling next continues with the following middleware, not calling it stops the chain
+type Middleware func(ctx *EventContext, next func())
+
+type EventContext struct {
+	// client which sent the event
+	Client 		*SocketClient
+	// name of the event
+	Event 		string
+	// event payload, can be replaced before it reaches the listeners
+	Data 		interface{}
+	// positional event arguments, can be replaced before they reach the listeners
+	Args 		[]interface{}
+	// acknowledgment, nil if the client doesn't expect one
+	Ack 		*Ack
+	// error which stopped the chain
+	err 		error
+	// event context
+	ctx 		context.Context
+}
+
+// Error stops the chain and sends an error packet to the client
+func (ctx *EventContext) Error(err error) {
+	ctx.err = err
+}
+
+func (ctx *EventContext) Err() error {
+	return ctx.err
+}
+
+// Context returns the event context, cancelled when the listeners return,
+// the client disconnects or the event timeout of the namespace expires
+func (ctx *EventContext) Context() context.Context {
+	return ctx.ctx
+}
+
+// WithContext replaces the event context passed to the following middleware and the listeners,
+// the context should be derived from the current one, e.g. to carry request scoped values
+func (ctx *EventContext) WithContext(c context.Context) {
+	ctx.ctx = c
+	ctx.Client.ctx = c
+}
+
+// Use registers middleware which runs for every event of the namespace in registration order
+func (namespace *Namespace) Use(middleware Middleware) {
+	namespace.mtx.Lock()
+	namespace.middleware = append(namespace.middleware, middleware)
+	namespace.mtx.Unlock()
+}
+
+// UseAll registers middleware which runs for every event of all namespaces,
+// before the middleware of the namespace
+func (server *Server) UseAll(middleware Middleware) {
+	server.middlewareMtx.Lock()
+	server.middleware = append(server.middleware, middleware)
+	server.middlewareMtx.Unlock()
+}
+
+func (namespace *Namespace) middlewareChain() []Middleware {
+	server := namespace.server
+	server.middlewareMtx.RLock()
+	chain := make([]Middleware, 0, len(server.middleware))
+	chain = append(chain, server.middleware...)
+	server.middlewareMtx.RUnlock()
+
+	namespace.mtx.RLock()
+	chain = append(chain, namespace.middleware...)
+	namespace.mtx.RUnlock()
+	return chain
+}
+
+// runs the middleware chain and the listeners for the event,
+// called from the namespace routine
+func (namespace *Namespace) handleEvent(evt *listenerEvent) {
+	eventCtx, cancel := namespace.eventContext(evt.client)
+	defer cancel()
+	// the span covers the middleware and the listeners, events expecting
+	// an acknowledgment are children of the ack round-trip span
+	tracer := namespace.server.tracer()
+	parent := tracer.Extract(eventCtx, tracing.Carrier(evt.trace))
+	if evt.ack != nil {
+		parent = evt.ack.traceContext(eventCtx)
+	}
+	eventCtx, span := tracer.Start(parent, tracing.EventSpan)
+	defer span.End()
+	span.SetAttribute(tracing.SessionKey, evt.client.uuid)
+	span.SetAttribute(tracing.NamespaceKey, namespace.name)
+	span.SetAttribute(tracing.EventKey, evt.mame)
+
+	socketClient := evt.client.wrap(namespace)
+	socketClient.ack = evt.ack
+	socketClient.ctx = eventCtx
+
+	ctx := &EventContext{
+		Client: 	socketClient,
+		Event: 		evt.mame,
+		Data: 		evt.data,
+		Args: 		evt.args,
+		Ack: 		evt.ack,
+		ctx: 		eventCtx,
+	}
+
+	chain := namespace.middlewareChain()
+	i := 0
+	var next func()
+	next = func() {
+		if ctx.err != nil {
+			return
+		}
+		if i < len(chain) {
+			middleware := chain[i]
+			i++
+			middleware(ctx, next)
+			return
+		}
+		namespace.dispatch(ctx.Client, ctx.Event, ctx.Data, ctx.Args)
+	}
+	next()
+
+	if ctx.err != nil {
+		span.RecordError(ctx.err)
+		evt.client.sendError(namespace.name, ctx.err)
+	}
+}
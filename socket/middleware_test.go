@@ -0,0 +1,166 @@
+package socket
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func middlewareTestConn(t *testing.T) (*Server, *Namespace, *testTransport) {
+	server := newTestServer(t, nil)
+	namespace, err := server.AddNamespace("/mw", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, conn := openTestConn(t, server, "/")
+	connectTestClient(t, client, "/mw")
+	return server, namespace, conn
+}
+
+func sendTestEvent(t *testing.T, conn *testTransport, event string, data interface{}) {
+	conn.send(t, &transport.Packet{PacketType: transport.Event, Endpoint: "/mw", Name: event, Data: data})
+}
+
+func awaitTestEvent(t *testing.T, c chan interface{}) interface{} {
+	select {
+		case data := <-c:
+			return data
+		case <-time.After(2 * time.Second):
+			t.Fatal("Listener wasn't called")
+			return nil
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	var mtx sync.Mutex
+	calls := make([]string, 0)
+	record := func(call string) {
+		mtx.Lock()
+		calls = append(calls, call)
+		mtx.Unlock()
+	}
+	layer := func(name string) Middleware {
+		return func(ctx *EventContext, next func()) {
+			record(name)
+			next()
+			record(name + " after")
+		}
+	}
+	namespace.Use(layer("first"))
+	server.UseAll(layer("server"))
+	namespace.Use(layer("second"))
+	done := make(chan interface{}, 1)
+	namespace.Listen("work", func(client *SocketClient, data interface{}) {
+		record("listener")
+		done <- data
+	})
+
+	probe := make(chan interface{}, 1)
+	namespace.Listen("probe", func(client *SocketClient, data interface{}) {
+		probe <- data
+	})
+
+	sendTestEvent(t, conn, "work", nil)
+	awaitTestEvent(t, done)
+	// the chain of the first event returned before the probe is handled
+	sendTestEvent(t, conn, "probe", nil)
+	awaitTestEvent(t, probe)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	expected := []string{"server", "first", "second", "listener", "second after", "first after", "server after"}
+	if !reflect.DeepEqual(calls[:len(expected)], expected) {
+		t.Fatalf("Expected %v, got %v", expected, calls)
+	}
+}
+
+func TestMiddlewareStopsWithoutNext(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	later := 0
+	namespace.Use(func(ctx *EventContext, next func()) {
+		if ctx.Event != "blocked" {
+			next()
+		}
+	})
+	namespace.Use(func(ctx *EventContext, next func()) {
+		if ctx.Event == "blocked" {
+			later++
+		}
+		next()
+	})
+	events := make(chan interface{}, 2)
+	namespace.Listen("blocked", func(client *SocketClient, data interface{}) {
+		events <- "blocked"
+	})
+	namespace.Listen("probe", func(client *SocketClient, data interface{}) {
+		events <- "probe"
+	})
+
+	sendTestEvent(t, conn, "blocked", nil)
+	sendTestEvent(t, conn, "probe", nil)
+	// events are handled in order so the blocked event was handled before the probe
+	if event := awaitTestEvent(t, events); event != "probe" {
+		t.Fatalf("Expected the blocked event to be stopped, got %v", event)
+	}
+	if later != 0 {
+		t.Fatal("Expected the following middleware not to run")
+	}
+	conn.expectNone(t, transport.Error, 20 * time.Millisecond)
+}
+
+func TestMiddlewareError(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	called := make(chan interface{}, 1)
+	namespace.Use(func(ctx *EventContext, next func()) {
+		ctx.Error(makeComplexError(Unauthorized, errors.New("Denied")))
+		next()
+	})
+	namespace.Use(func(ctx *EventContext, next func()) {
+		called <- "middleware"
+		next()
+	})
+	namespace.Listen("work", func(client *SocketClient, data interface{}) {
+		called <- "listener"
+	})
+
+	sendTestEvent(t, conn, "work", nil)
+	packet := conn.expect(t, transport.Error)
+	data, _ := packet.Data.(map[string]interface{})
+	if code, _ := data["errorCode"].(float64); int(code) != Unauthorized || data["cause"] != "Denied" || packet.Endpoint != "/mw" {
+		t.Fatalf("Expected the middleware error, got %+v", packet)
+	}
+	select {
+		case call := <-called:
+			t.Fatalf("Expected the chain to stop, %v was called", call)
+		default:
+	}
+}
+
+func TestMiddlewareReplacesData(t *testing.T) {
+	server, namespace, conn := middlewareTestConn(t)
+	defer server.Stop()
+
+	namespace.Use(func(ctx *EventContext, next func()) {
+		ctx.Data = ctx.Data.(string) + " world"
+		next()
+	})
+	received := make(chan interface{}, 1)
+	namespace.Listen("greet", func(client *SocketClient, data interface{}) {
+		received <- data
+	})
+
+	sendTestEvent(t, conn, "greet", "hello")
+	if data := awaitTestEvent(t, received); data != "hello world" {
+		t.Fatalf("Expected the replaced data, got %v", data)
+	}
+}
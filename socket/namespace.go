@@ -3,119 +3,261 @@ package socket
 import (
 	"sync"
 	"errors"
-	"github.com/sirupsen/logrus"
 	"github.com/ppincak/gse/socket/transport"
 	"github.com/ppincak/gse/socket/stats"
 )
 
 type Namespace struct {
 	name      	string
+	// namespace configuration
+	conf 		*NamespaceConf
 	// reference to server
 	server		*Server
-	// rooms in the namespace
-	rooms		map[string]*Room
+	// rooms in the namespace indexed by the levels of their names
+	rooms		*roomTrie
+	// rooms by name
+	roomNames 	map[string]*Room
+	// rooms by id
+	roomIds 	map[string]*Room
 	// clients in the namespace
 	clients		map[string]*Client
 	// listeners
 	*Listeners
 	// events channel
 	evc       	chan *listenerEvent
-	// stopping channel
+	// closed to stop the namespace routine, nil while the routine isn't running
 	stopc     	chan struct{}
 	// lock
 	mtx       	*sync.RWMutex
+	// logger with the namespace field
+	logger 		Logger
+	// inbound event middleware
+	middleware 	[]Middleware
+	// outbound packet interceptors
+	outbound 	[]OutboundInterceptor
+	// dynamic namespace which created this namespace, nil for static namespaces
+	dynamic 	*DynamicNamespace
+	// flag indicating that the namespace was removed from the server
+	closed 		bool
+	// events of a closing namespace which didn't fit the buffer, handled when the routine drains
+	overflow 	[]*listenerEvent
 }
 
 func rootNamespace(server *Server) *Namespace {
-	return newNamespace("/", server)
+	return newNamespace("/", server, nil)
 }
 
-func newNamespace(name string, server *Server) *Namespace {
+func newNamespace(name string, server *Server, conf *NamespaceConf) *Namespace {
+	if conf == nil {
+		conf = DefaultNamespaceConf()
+	}
+	bufferSize := conf.EventBufferSize
+	if bufferSize == 0 {
+		bufferSize = server.config().EventBufferSize
+	}
+	logger := server.logger.WithFields(Fields{NamespaceField: name})
 	return &Namespace{
 		name: 		name,
+		conf: 		conf,
 		server: 	server,
-		rooms: 		make(map[string]*Room),
+		rooms: 		newRoomTrie(),
+		roomNames: 	make(map[string]*Room),
+		roomIds: 	make(map[string]*Room),
 		clients:	make(map[string]*Client),
-		Listeners:	newListeners(),
-		evc: 		make(chan *listenerEvent, server.conf.EventBufferSize),
-		stopc: 		make(chan struct{}),
+		Listeners:	newListeners(logger),
+		evc: 		make(chan *listenerEvent, bufferSize),
 		mtx:        new(sync.RWMutex),
+		logger: 	logger,
+	}
+}
+
+// Run handles the events of the namespace until it is stopped, it returns
+// immediately if the routine is already running
+func (namespace *Namespace) Run() {
+	if stopc := namespace.prepare(); stopc != nil {
+		namespace.run(stopc)
+	}
+}
+
+// starts the routine in a goroutine, the namespace can be stopped as soon as it returns
+func (namespace *Namespace) start() {
+	if stopc := namespace.prepare(); stopc != nil {
+		go namespace.run(stopc)
+	}
+}
+
+// marks the routine as running, returns nil if it already runs
+func (namespace *Namespace) prepare() chan struct{} {
+	namespace.mtx.Lock()
+	defer namespace.mtx.Unlock()
+	if namespace.stopc != nil {
+		return nil
 	}
+	namespace.stopc = make(chan struct{})
+	return namespace.stopc
 }
 
-func (namespace *Namespace)  Run() {
-	logrus.Infof("Running namespace: %s routine", namespace.name)
+func (namespace *Namespace) run(stopc chan struct{}) {
+	namespace.logger.Debugf("Namespace routine started")
+	defer namespace.logger.Debugf("Namespace routine stopped")
+	defer func() {
+		namespace.mtx.Lock()
+		if namespace.stopc == stopc {
+			namespace.stopc = nil
+		}
+		namespace.mtx.Unlock()
+	}()
 
-	l := namespace.Listeners
 	for {
 		select {
-			case msg := <- l.liregc:
-				switch msg.listenerType {
-					case connectListener:
-						logrus.Infof("Namespace: %s - registering connect listener", namespace.name)
-						l.clientCon = append(l.clientCon, msg.ConnectListener)
-					case disconnectListener:
-						logrus.Infof("Namespace: %s - registering disconnect listener", namespace.name)
-						l.clientDis = append(l.clientDis, msg.DisconnectListener)
-					case eventListener:
-						logrus.Infof("Namespace: %s - registering listener for event: %s", namespace.name,  msg.event)
-						if listeners, ok := l.events[msg.event]; ok {
-							l.events[msg.event] = append(listeners, msg.EventListener)
-						} else {
-							l.events[msg.event] = []EventListener {msg.EventListener}
-						}
+			case evt := <- namespace.evc:
+				namespace.handle(evt)
+				if evt.listenerType == disconnectListener && namespace.dynamic != nil && namespace.server.releaseDynamic(namespace) {
+					namespace.drain()
+					return
 				}
-		case evt := <- namespace.evc:
-			switch evt.listenerType {
-				case connectListener:
-					for _, listener := range l.clientCon {
-						listener(evt.client.wrap(namespace))
-					}
-				case disconnectListener:
-					for _, listener := range l.clientDis {
-						listener(evt.client.wrap(namespace))
-					}
-				case eventListener:
-					if listeners, ok := l.events[evt.mame]; ok {
-						for _, listener := range listeners {
-							socketClient := evt.client.wrap(namespace)
-							socketClient.ack = evt.ack
-							listener(socketClient, evt.data)
-						}
-					}
-				}
-			case <- namespace.stopc:
-				logrus.Infof("Stopping namespace: %s routine", namespace.name)
+			case <- stopc:
+				namespace.drain()
 				return
 		}
 	}
 }
 
+func (namespace *Namespace) handle(evt *listenerEvent) {
+	switch evt.listenerType {
+		case connectListener:
+			namespace.onConnect(evt.client.wrap(namespace))
+		case disconnectListener:
+			namespace.onDisconnect(evt.client.wrap(namespace))
+		case eventListener:
+			if namespace.conf.DispatchMode == ConcurrentDispatch {
+				go namespace.handleEvent(evt)
+			} else {
+				namespace.handleEvent(evt)
+			}
+	}
+}
+
+// handles the events queued before the routine was stopped
+func (namespace *Namespace) drain() {
+	for {
+		select {
+			case evt := <- namespace.evc:
+				namespace.handle(evt)
+				continue
+			default:
+		}
+		namespace.mtx.Lock()
+		overflow := namespace.overflow
+		namespace.overflow = nil
+		namespace.mtx.Unlock()
+		if len(overflow) == 0 {
+			return
+		}
+		for _, evt := range overflow {
+			namespace.handle(evt)
+		}
+	}
+}
+
+// queues the event for the routine, a closing namespace can be removed from its own
+// routine so its events never block and those which don't fit the buffer are kept for drain
+func (namespace *Namespace) queue(evt *listenerEvent) {
+	namespace.mtx.Lock()
+	if !namespace.closed {
+		namespace.mtx.Unlock()
+		namespace.evc <- evt
+		return
+	}
+	select {
+		case namespace.evc <- evt:
+		default:
+			namespace.overflow = append(namespace.overflow, evt)
+	}
+	namespace.mtx.Unlock()
+}
+
+// marks the namespace as removed, returns its clients and false if it was already closed
+func (namespace *Namespace) close() ([]*Client, bool) {
+	namespace.mtx.Lock()
+	defer namespace.mtx.Unlock()
+	if namespace.closed {
+		return nil, false
+	}
+	namespace.closed = true
+	clients := make([]*Client, 0, len(namespace.clients))
+	for _, client := range namespace.clients {
+		clients = append(clients, client)
+	}
+	return clients, true
+}
+
+func (namespace *Namespace) closeIfEmpty() bool {
+	namespace.mtx.Lock()
+	defer namespace.mtx.Unlock()
+	if namespace.closed || len(namespace.clients) > 0 {
+		return false
+	}
+	namespace.closed = true
+	return true
+}
+
+// Stop stops the namespace routine after it handles the queued events, it doesn't wait for it
 func (namespace *Namespace) Stop() {
-	logrus.Infof("Stopped namespace: %s routine", namespace.name)
-	namespace.stopc <- struct{}{}
+	namespace.mtx.Lock()
+	stopc := namespace.stopc
+	namespace.stopc = nil
+	namespace.mtx.Unlock()
+	if stopc != nil {
+		namespace.logger.Debugf("Stopping namespace routine")
+		close(stopc)
+	}
 }
 
 func (namespace *Namespace) GetName() string {
 	return namespace.name
 }
 
-func (namespace *Namespace) AddRoom(roomName string) *Room {
-	namespace.mtx.Lock()
+func (namespace *Namespace) AddRoom(roomName string) (*Room, error) {
+	if err := validateRoomName(roomName); err != nil {
+		return nil, err
+	}
+	if !namespace.server.reserveRoom() {
+		return nil, makeError(MaxRoomsReached)
+	}
 	room := NewRoom(namespace, roomName)
-	namespace.rooms[room.uuid] = room
+	if namespace.conf.History != nil {
+		room.history = namespace.conf.History.newStore(namespace.name, roomName)
+	}
+	namespace.mtx.Lock()
+	added := namespace.rooms.insert(room)
+	if added {
+		namespace.roomNames[room.name] = room
+		namespace.roomIds[room.uuid] = room
+	}
 	namespace.mtx.Unlock()
+	if !added {
+		namespace.server.releaseRoom()
+		return nil, makeError(RoomAlreadyExists)
+	}
 	namespace.server.stats.Inc(stats.OpenedRooms)
-	return room
+	return room, nil
 }
 
 func (namespace *Namespace) GetRoom(roomName string) (*Room, error) {
 	namespace.mtx.RLock()
 	defer namespace.mtx.RUnlock()
-	for _, room := range namespace.rooms {
-		if room.name == roomName {
-			return room, nil
-		}
+	if room, ok := namespace.roomNames[roomName]; ok {
+		return room, nil
+	}
+	return nil, errors.New("Room not found")
+}
+
+func (namespace *Namespace) GetRoomById(roomId string) (*Room, error) {
+	namespace.mtx.RLock()
+	defer namespace.mtx.RUnlock()
+	if room, ok := namespace.roomIds[roomId]; ok {
+		return room, nil
 	}
 	return nil, errors.New("Room not found")
 }
@@ -123,84 +265,97 @@ func (namespace *Namespace) GetRoom(roomName string) (*Room, error) {
 func (namespace *Namespace) GetRooms() []*Room {
 	namespace.mtx.RLock()
 	defer namespace.mtx.RUnlock()
-	rooms := make([]*Room, len(namespace.rooms))
-	i := 0
-	for _, room := range namespace.rooms {
-		rooms[i] = room
-		i++
+	rooms := make([]*Room, 0, len(namespace.roomIds))
+	for _, room := range namespace.roomIds {
+		rooms = append(rooms, room)
 	}
 	return rooms
 }
 
 func (namespace *Namespace) RemoveRoom(roomName string) {
 	namespace.mtx.Lock()
-	defer namespace.mtx.Unlock()
-	for _, room := range namespace.rooms {
-		if room.name == roomName {
-			room.Destroy()
-			delete(namespace.rooms, roomName)
-			namespace.server.stats.Inc(stats.ClosedRooms)
-			return
-		}
+	removed := namespace.rooms.remove(roomName)
+	if removed != nil {
+		delete(namespace.roomNames, removed.name)
+		delete(namespace.roomIds, removed.uuid)
 	}
-}
+	namespace.mtx.Unlock()
 
-func (namespace *Namespace) joinRoom(roomName string, client *Client) {
-	namespace.mtx.RLock()
-	defer namespace.mtx.RUnlock()
-	for _, room := range namespace.rooms {
-		if room.name == roomName {
-			room.addClient(client)
-			client.joinRoom(room)
-			return
-		}
+	if removed != nil {
+		removed.Destroy()
+		namespace.server.releaseRoom()
+		namespace.server.stats.Inc(stats.ClosedRooms)
 	}
 }
 
 func (namespace *Namespace) GetClient(sessiondId string) *Client {
-	namespace.mtx.RUnlock()
+	namespace.mtx.RLock()
 	defer namespace.mtx.RUnlock()
 	return namespace.clients[sessiondId]
 }
 
 func (namespace *Namespace) GetClients() []*Client {
-	namespace.mtx.RUnlock()
-	defer namespace.mtx.RUnlock()
-	clients := make([]*Client, len(namespace.clients))
-	i := 0
-	for _, client := range namespace.clients {
-		clients[i] = client
-		i++
-	}
-	return clients
+	return namespace.clientList()
 }
 
 func (namespace *Namespace) SendEvent(event string, data interface{}) {
+	namespace.emit(namespace.server.Context(), namespace.clientList(), &transport.Packet{
+		Name: 		event,
+		Data: 		data,
+		PacketType: transport.Event,
+		Endpoint: 	namespace.name,
+	})
+}
+
+func (namespace *Namespace) clientList() []*Client {
 	namespace.mtx.RLock()
+	defer namespace.mtx.RUnlock()
+	clients := make([]*Client, 0, len(namespace.clients))
 	for _, client := range namespace.clients {
-		client.sendEvent(event, data, namespace.name)
+		clients = append(clients, client)
 	}
-	namespace.mtx.RUnlock()
+	return clients
 }
 
-func (namespace *Namespace) addClient(client *Client) {
+func (namespace *Namespace) addClient(client *Client) error {
 	namespace.mtx.Lock()
+	if namespace.closed {
+		namespace.mtx.Unlock()
+		return errNamespaceClosed
+	}
+	if max := namespace.conf.MaxNumOfClients; max > 0 && len(namespace.clients) >= int(max) {
+		namespace.mtx.Unlock()
+		return makeError(MaxClientsReached)
+	}
+	// the client knows the namespace before it is notified about the connection
+	if !client.addNamespace(namespace) {
+		namespace.mtx.Unlock()
+		return errClientClosed
+	}
 	namespace.clients[client.uuid] = client
 	namespace.mtx.Unlock()
 	namespace.evc <- &listenerEvent{
 		listenerType: connectListener,
 		client: client,
 	}
-	client.notify(transport.Connect, namespace.name)
+	client.notify(transport.Connect, namespace)
+	return nil
+}
+
+// removes the client from the namespace and the namespace from the client
+func (namespace *Namespace) disconnectClient(client *Client) {
+	client.leaveNamespaceRooms(namespace)
+	client.removeNamespace(namespace)
+	namespace.removeClient(client)
 }
 
 func (namespace *Namespace) removeClient(client *Client) {
 	namespace.mtx.Lock()
 	delete(namespace.clients, client.uuid)
 	namespace.mtx.Unlock()
-	namespace.evc <- &listenerEvent{
+	namespace.queue(&listenerEvent{
 		listenerType: disconnectListener,
 		client: client,
-	}
-	client.notify(transport.Disconnect, namespace.name)
+	})
+	client.notify(transport.Disconnect, namespace)
 }
\ No newline at end of file
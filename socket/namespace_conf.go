@@ -0,0 +1,68 @@
+package socket
+
+import (
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+type DispatchMode string
+
+const(
+	// events are handled one by one in the namespace routine
+	SequentialDispatch 	DispatchMode = "sequential"
+	// every event is handled in its own goroutine
+	ConcurrentDispatch 	DispatchMode = "concurrent"
+)
+
+// AuthFunc decides if the client can connect to the namespace,
+// the connect packet carries the query string sent by the client
+type AuthFunc func(client *Client, packet *transport.Packet) error
+
+type NamespaceConf struct {
+	// size of the event buffer, zero uses the server configuration
+	EventBufferSize 	int 				`json:"eventBuffer"`
+	// maximum number of clients in the namespace, zero means unlimited
+	MaxNumOfClients 	int32 				`json:"numberOfClients"`
+	// rate limits for packets sent to the namespace, applied on top of the server limits
+	RateLimit 			*RateLimitConf 		`json:"rateLimit"`
+	// how the events are dispatched to the listeners
+	DispatchMode 		DispatchMode 		`json:"dispatchMode"`
+	// deadline of the event context passed to the listeners, zero means no deadline
+	EventTimeout 		Duration 			`json:"eventTimeout"`
+	// history kept by the rooms of the namespace, nil disables it
+	History 			*HistoryConf 		`json:"history"`
+	// authorization of connecting clients, nil allows everyone
+	Authorize 			AuthFunc 			`json:"-"`
+}
+
+func DefaultNamespaceConf() *NamespaceConf {
+	return &NamespaceConf{
+		DispatchMode: SequentialDispatch,
+	}
+}
+
+func (conf *NamespaceConf) Validate() error {
+	confErr := &ConfError{}
+
+	if conf.EventBufferSize < 0 {
+		confErr.add("eventBuffer must not be negative, got %d", conf.EventBufferSize)
+	}
+	if conf.MaxNumOfClients < 0 {
+		confErr.add("numberOfClients must not be negative, got %d", conf.MaxNumOfClients)
+	}
+	switch conf.DispatchMode {
+		case "", SequentialDispatch, ConcurrentDispatch:
+		default:
+			confErr.add("dispatchMode must be one of sequential, concurrent, got %q", conf.DispatchMode)
+	}
+	if conf.EventTimeout < 0 {
+		confErr.add("eventTimeout must not be negative, got %s", time.Duration(conf.EventTimeout))
+	}
+	validateRateLimit(confErr, "rateLimit", conf.RateLimit)
+	validateHistory(confErr, "history", conf.History)
+
+	if len(confErr.Problems) > 0 {
+		return confErr
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func TestRemoveNamespaceFromListener(t *testing.T) {
+	conf := DefaultConf()
+	conf.EventBufferSize = 2
+	server := newTestServer(t, conf)
+	defer server.Stop()
+	namespace, err := server.AddNamespace("/t", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var disconnects int32
+	namespace.AddDisconnectListener(func(client *SocketClient) {
+		atomic.AddInt32(&disconnects, 1)
+	})
+	removed := make(chan error, 1)
+	namespace.Listen("remove", func(client *SocketClient, data interface{}) {
+		removed <- server.RemoveNamespace("/t")
+	})
+
+	clients := make([]*Client, 6)
+	for i := range clients {
+		clients[i] = openTestClient(t, server, "/")
+		connectTestClient(t, clients[i], "/t")
+	}
+	clients[0].onPacket(&transport.Packet{PacketType: transport.Event, Endpoint: "/t", Name: "remove"}, 0)
+
+	select {
+		case err := <-removed:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("RemoveNamespace called from a listener deadlocked")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&disconnects) != int32(len(clients)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := atomic.LoadInt32(&disconnects); count != int32(len(clients)) {
+		t.Fatalf("Expected %d disconnect events, got %d", len(clients), count)
+	}
+	for _, client := range clients {
+		if client.getNamespace("/t") != nil {
+			t.Errorf("Client %s still references the removed namespace", client.uuid)
+		}
+	}
+}
+
+func TestDynamicNamespaceConf(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	server.DynamicNamespace(func(name string, client *Client) bool {
+		return strings.HasPrefix(name, "/game-") || name == "/broken"
+	}, func(name string) *NamespaceConf {
+		if name == "/broken" {
+			return &NamespaceConf{MaxNumOfClients: -1}
+		}
+		conf := DefaultNamespaceConf()
+		conf.MaxNumOfClients = 1
+		return conf
+	}, nil)
+
+	connectTestClient(t, openTestClient(t, server, "/"), "/game-1")
+	second := openTestClient(t, server, "/")
+	err := server.addNamespaceClient(second, &transport.Packet{PacketType: transport.Connect, Endpoint: "/game-1"})
+	if err == nil {
+		t.Fatal("Expected the client limit of the dynamic namespace to apply")
+	}
+	connectTestClient(t, second, "/game-2")
+
+	err = server.addNamespaceClient(second, &transport.Packet{PacketType: transport.Connect, Endpoint: "/broken"})
+	if err == nil {
+		t.Fatal("Expected an invalid configuration to reject the connection")
+	}
+}
+
+func TestRejectedDynamicConnectLeavesNoNamespace(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	var setups int32
+	server.DynamicNamespace(func(name string, client *Client) bool {
+		return strings.HasPrefix(name, "/room-")
+	}, func(name string) *NamespaceConf {
+		conf := DefaultNamespaceConf()
+		conf.Authorize = func(client *Client, packet *transport.Packet) error {
+			if client.Handshake().Query.Get("token") != "secret" {
+				return errors.New("Bad token")
+			}
+			return nil
+		}
+		return conf
+	}, func(namespace *Namespace) {
+		atomic.AddInt32(&setups, 1)
+	})
+
+	rejected := openTestClient(t, server, "/")
+	for i := 0; i < 50; i++ {
+		err := server.addNamespaceClient(rejected, &transport.Packet{PacketType: transport.Connect, Endpoint: fmt.Sprintf("/room-%d", i)})
+		if err == nil {
+			t.Fatal("Expected the connection to be rejected")
+		}
+	}
+	if namespaces := server.GetAllNamespaces(); len(namespaces) != 0 {
+		t.Fatalf("Expected no namespaces after rejected connects, got %d", len(namespaces))
+	}
+
+	// concurrent joins of the same name set up a single namespace
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connectTestClient(t, openTestClient(t, server, "/?token=secret"), "/room-shared")
+		}()
+	}
+	wg.Wait()
+	if count := atomic.LoadInt32(&setups) - 50; count != 1 {
+		t.Fatalf("Expected the shared namespace to be set up once, got %d", count)
+	}
+	if namespaces := server.GetAllNamespaces(); len(namespaces) != 1 {
+		t.Fatalf("Expected the shared namespace only, got %d", len(namespaces))
+	}
+}
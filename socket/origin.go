@@ -0,0 +1,145 @@
+package socket
+
+import (
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+func newUpgrader(conf *ServerConf) *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize: 	conf.ReadBufferSize,
+		WriteBufferSize: 	conf.WriteBufferSize,
+		Subprotocols: 		conf.Subprotocols,
+		HandshakeTimeout: 	time.Duration(conf.HandshakeTimeout),
+	}
+	if len(conf.AllowedOrigins) > 0 {
+		upgrader.CheckOrigin = originChecker(conf.AllowedOrigins)
+	}
+	if conf.UpgraderHook != nil {
+		conf.UpgraderHook(upgrader)
+	}
+	return upgrader
+}
+
+func originChecker(patterns []string) func(r *http.Request) bool {
+	normalized := normalizeOrigins(patterns)
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		// non browser clients don't send the origin header
+		if origin == "" {
+			return true
+		}
+		_, ok := matchingOrigin(normalized, origin)
+		return ok
+	}
+}
+
+func normalizeOrigins(patterns []string) []string {
+	normalized := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		normalized[i] = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+	}
+	return normalized
+}
+
+// returns the pattern matching the origin, the wildcard "*" is returned
+// only when no other pattern matches
+func matchingOrigin(normalized []string, origin string) (string, bool) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	wildcard := false
+	for _, pattern := range normalized {
+		if pattern == "*" {
+			wildcard = true
+		} else if matchOrigin(pattern, u) {
+			return pattern, true
+		}
+	}
+	return "*", wildcard
+}
+
+// patterns containing a scheme are matched against "scheme://host",
+// patterns without a scheme only against the host
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+	value := origin.Host
+	if strings.Contains(pattern, "://") {
+		value = origin.Scheme + "://" + origin.Host
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
+
+func isSecureRequest(r *http.Request, trustForwardedProto bool) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return trustForwardedProto && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func responseHeader(headers map[string]string) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	header := make(http.Header, len(headers))
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+	return header
+}
+
+// applies the origin check of the upgrader to plain http requests,
+// without a custom check only same origin requests are accepted like in the upgrader
+func checkOrigin(upgrader *websocket.Upgrader, r *http.Request) bool {
+	if upgrader.CheckOrigin != nil {
+		return upgrader.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// lets browsers read the cross-origin responses of the polling and event stream transports,
+// the origin is echoed with credentials only when it matches a configured pattern,
+// origins allowed only by the wildcard "*" get the wildcard without credentials
+func allowOrigin(w http.ResponseWriter, r *http.Request, conf *ServerConf) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(conf.AllowedOrigins) == 0 {
+		return
+	}
+	pattern, ok := matchingOrigin(normalizeOrigins(conf.AllowedOrigins), origin)
+	if !ok {
+		return
+	}
+	if pattern == "*" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Add("Vary", "Origin")
+}
+
+// answers the preflight of cross-origin polling requests
+func allowPreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
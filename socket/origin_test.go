@@ -0,0 +1,85 @@
+package socket
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginChecker(t *testing.T) {
+	tests := []struct {
+		name 		string
+		patterns 	[]string
+		origin 		string
+		allowed 	bool
+	}{
+		{"matched host", []string{"app.example.com"}, "https://app.example.com", true},
+		{"matched scheme and host", []string{"https://*.example.com/"}, "https://App.Example.com", true},
+		{"other scheme", []string{"https://app.example.com"}, "http://app.example.com", false},
+		{"unmatched", []string{"app.example.com"}, "https://evil.example.com", false},
+		{"wildcard", []string{"*"}, "https://any.org", true},
+		{"missing origin", []string{"app.example.com"}, "", true},
+		{"invalid origin", []string{"*"}, "null", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			if allowed := originChecker(test.patterns)(r); allowed != test.allowed {
+				t.Fatalf("Expected %v, got %v", test.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestCheckOriginWithoutPatterns(t *testing.T) {
+	upgrader := newUpgrader(DefaultConf())
+	r := httptest.NewRequest("GET", "http://gse.io/", nil)
+	r.Header.Set("Origin", "https://gse.io")
+	if !checkOrigin(upgrader, r) {
+		t.Fatal("Expected the same origin to be allowed")
+	}
+	r.Header.Set("Origin", "https://evil.io")
+	if checkOrigin(upgrader, r) {
+		t.Fatal("Expected a foreign origin to be rejected")
+	}
+}
+
+func TestAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name 			string
+		patterns 		[]string
+		origin 			string
+		allowOrigin 	string
+		credentials 	string
+	}{
+		{"matched", []string{"https://app.example.com"}, "https://app.example.com", "https://app.example.com", "true"},
+		{"unmatched", []string{"https://app.example.com"}, "https://evil.example.com", "", ""},
+		{"wildcard", []string{"*"}, "https://any.org", "*", ""},
+		{"pattern preferred over wildcard", []string{"*", "app.example.com"}, "https://app.example.com", "https://app.example.com", "true"},
+		{"missing origin", []string{"*"}, "", "", ""},
+		{"no patterns", nil, "https://app.example.com", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := DefaultConf()
+			conf.AllowedOrigins = test.patterns
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			w := httptest.NewRecorder()
+			allowOrigin(w, r, conf)
+
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != test.allowOrigin {
+				t.Fatalf("Expected allowed origin %q, got %q", test.allowOrigin, origin)
+			}
+			if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials != test.credentials {
+				t.Fatalf("Expected credentials %q, got %q", test.credentials, credentials)
+			}
+		})
+	}
+}
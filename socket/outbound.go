@@ -0,0 +1,117 @@
+package socket
+
+import (
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// OutboundInterceptor can rewrite, enrich or drop a packet before it is encoded for a client
+type OutboundInterceptor func(ctx *OutboundContext)
+
+type OutboundContext struct {
+	// client receiving the packet
+	Client 		*Client
+	// copy of the packet for this client, maps, slices and byte slices in Data and Args
+	// are copied as well, other reference values are shared and must be replaced, not modified
+	Packet 		*transport.Packet
+	// flag indicating that the packet won't be sent
+	dropped 	bool
+}
+
+// Drop prevents the packet from being sent to the client
+func (ctx *OutboundContext) Drop() {
+	ctx.dropped = true
+}
+
+// UseOutbound registers an interceptor which runs for every packet sent
+// through the namespace in registration order
+func (namespace *Namespace) UseOutbound(interceptor OutboundInterceptor) {
+	namespace.mtx.Lock()
+	namespace.outbound = append(namespace.outbound, interceptor)
+	namespace.mtx.Unlock()
+}
+
+func (namespace *Namespace) outboundInterceptors() []OutboundInterceptor {
+	namespace.mtx.RLock()
+	defer namespace.mtx.RUnlock()
+	if len(namespace.outbound) == 0 {
+		return nil
+	}
+	interceptors := make([]OutboundInterceptor, len(namespace.outbound))
+	copy(interceptors, namespace.outbound)
+	return interceptors
+}
+
+// runs the interceptors on a copy of the packet, returns nil if the packet was dropped
+func (namespace *Namespace) intercept(client *Client, packet *transport.Packet, interceptors []OutboundInterceptor) *transport.Packet {
+	if len(interceptors) == 0 {
+		return packet
+	}
+	copied := *packet
+	copied.Data = copyValue(packet.Data)
+	if packet.Args != nil {
+		copied.Args = copyValue(packet.Args).([]interface{})
+	}
+	ctx := &OutboundContext{
+		Client: client,
+		Packet: &copied,
+	}
+	for _, interceptor := range interceptors {
+		interceptor(ctx)
+		if ctx.dropped {
+			return nil
+		}
+	}
+	return ctx.Packet
+}
+
+// sends the packet to all the clients, the packet is encoded only once
+// per protocol when the namespace has no interceptors
+func (namespace *Namespace) broadcast(clients []*Client, packet *transport.Packet) {
+	interceptors := namespace.outboundInterceptors()
+	if len(interceptors) == 0 {
+		// encoded once per protocol
+		encoded := make(map[string][]transport.Frame)
+		for _, client := range clients {
+			protocol := client.codec.Name()
+			frames, ok := encoded[protocol]
+			if !ok {
+				var err error
+				if frames, err = client.codec.Encode(packet); err != nil {
+					namespace.logger.WithFields(Fields{EventField: packet.Name}).Errorf("%s: %v", Errors[FailedToParsePacket], err)
+					return
+				}
+				encoded[protocol] = frames
+			}
+			client.sendFrames(frames)
+		}
+		return
+	}
+
+	for _, client := range clients {
+		if intercepted := namespace.intercept(client, packet, interceptors); intercepted != nil {
+			client.writePacket(intercepted)
+		}
+	}
+}
+
+// copies the maps and slices decoded payloads are made of so interceptors
+// can modify them without affecting the other recipients
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+		case map[string]interface{}:
+			copied := make(map[string]interface{}, len(value))
+			for key, item := range value {
+				copied[key] = copyValue(item)
+			}
+			return copied
+		case []interface{}:
+			copied := make([]interface{}, len(value))
+			for i, item := range value {
+				copied[i] = copyValue(item)
+			}
+			return copied
+		case []byte:
+			return append([]byte(nil), value...)
+	}
+	return value
+}
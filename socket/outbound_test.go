@@ -0,0 +1,58 @@
+package socket
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func TestInterceptorRedactsOneRecipient(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	redacted := openTestClient(t, server, "/")
+	other := openTestClient(t, server, "/")
+
+	// modifies the payload in place for a single client
+	server.UseOutbound(func(ctx *OutboundContext) {
+		if ctx.Client != redacted {
+			return
+		}
+		data := ctx.Packet.Data.(map[string]interface{})
+		delete(data, "secret")
+		data["nested"].(map[string]interface{})["token"] = "***"
+		ctx.Packet.Args[1].([]interface{})[0] = "***"
+	})
+
+	newPacket := func() *transport.Packet {
+		data := map[string]interface{}{
+			"secret": "s",
+			"nested": map[string]interface{}{"token": "t"},
+		}
+		return &transport.Packet{
+			PacketType: transport.Event,
+			Name: 		"message",
+			Data: 		data,
+			Args: 		[]interface{}{data, []interface{}{"t"}},
+		}
+	}
+	packet := newPacket()
+	interceptors := server.outboundInterceptors()
+
+	first := server.intercept(redacted, packet, interceptors)
+	second := server.intercept(other, packet, interceptors)
+
+	if _, ok := first.Data.(map[string]interface{})["secret"]; ok {
+		t.Fatal("Expected the secret to be redacted for the first client")
+	}
+	if token := first.Args[1].([]interface{})[0]; token != "***" {
+		t.Fatalf("Expected a redacted argument, got %v", token)
+	}
+	expected := newPacket()
+	if !reflect.DeepEqual(second.Data, expected.Data) || !reflect.DeepEqual(second.Args, expected.Args) {
+		t.Fatalf("Expected the second client to get the original payload, got %v %v", second.Data, second.Args)
+	}
+	if !reflect.DeepEqual(packet.Data, expected.Data) || !reflect.DeepEqual(packet.Args, expected.Args) {
+		t.Fatalf("Expected the broadcast packet to stay unchanged, got %v %v", packet.Data, packet.Args)
+	}
+}
@@ -0,0 +1,325 @@
+package socket
+
+import (
+	"context"
+	"sync"
+	"time"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// ResumeParam is the query parameter with the session id of a previous connection,
+// its unacknowledged reliable messages are delivered over the new connection once
+// the resume hook accepts it or the client is bound to the user of the previous session
+const ResumeParam = "resume"
+
+const(
+	RetryInterval 		= 5 * time.Second
+	MaxDeliveryAttempts = 10
+	OutboxRetention 	= time.Minute
+	MaxPendingMessages 	= 1000
+)
+
+// ResumeAuthFunc decides if the client can take over the reliable messages of the previous
+// session, it is called when the connection is opened with the resume query parameter
+type ResumeAuthFunc func(client *Client, sessionId string) bool
+
+type ReliableConf struct {
+	// duration after which an unacknowledged message is sent again
+	RetryInterval 	Duration 	`json:"retryInterval"`
+	// maximum number of sends of a message, zero means unlimited
+	MaxAttempts 	int 		`json:"maxAttempts"`
+	// duration for which the messages of a disconnected client are kept
+	// for a reconnect, zero drops them on disconnect
+	Retention 		Duration 	`json:"retention"`
+	// maximum number of unacknowledged messages of a client, further messages are
+	// dropped, zero uses the default
+	MaxPending 		int 		`json:"maxPending"`
+	// authorizes resuming a session when the connection is opened, without it or when it
+	// refuses, the session is resumed only after the client is bound to the user of the session
+	AuthorizeResume ResumeAuthFunc 	`json:"-"`
+}
+
+func DefaultReliableConf() *ReliableConf {
+	return &ReliableConf{
+		RetryInterval: 	Duration(RetryInterval),
+		MaxAttempts: 	MaxDeliveryAttempts,
+		Retention: 		Duration(OutboxRetention),
+		MaxPending: 	MaxPendingMessages,
+	}
+}
+
+func validateReliable(confErr *ConfError, field string, conf *ReliableConf) {
+	if conf == nil {
+		return
+	}
+	if conf.RetryInterval <= 0 {
+		confErr.add("%s.retryInterval must be positive, got %s", field, time.Duration(conf.RetryInterval))
+	}
+	if conf.MaxAttempts < 0 {
+		confErr.add("%s.maxAttempts must not be negative, got %d", field, conf.MaxAttempts)
+	}
+	if conf.Retention < 0 {
+		confErr.add("%s.retention must not be negative, got %s", field, time.Duration(conf.Retention))
+	}
+	if conf.MaxPending < 0 {
+		confErr.add("%s.maxPending must not be negative, got %d", field, conf.MaxPending)
+	}
+}
+
+func maxPending(conf *ReliableConf) int {
+	if conf.MaxPending > 0 {
+		return conf.MaxPending
+	}
+	return MaxPendingMessages
+}
+
+func (server *Server) reliableConf() *ReliableConf {
+	if conf := server.config().Reliable; conf != nil {
+		return conf
+	}
+	return DefaultReliableConf()
+}
+
+type outboxMessage struct {
+	// packet with the message id
+	packet 		*transport.Packet
+	// number of sends
+	attempts 	int
+	// time of the last send
+	sentAt 		time.Time
+}
+
+// outbox keeps the reliable messages of a client until they are acknowledged,
+// the lock is taken before the client lock
+type outbox struct {
+	// id of the last message
+	lastId 		int64
+	// unacknowledged messages by id
+	messages 	map[int64]*outboxMessage
+	// sends the unacknowledged messages again, nil when nothing is pending
+	retry 		*time.Timer
+	// drops the outbox of a disconnected client which didn't reconnect
+	expiry 		*time.Timer
+	// user of the disconnected client, only the same user can resume the outbox
+	userId 		string
+	mtx 		*sync.Mutex
+}
+
+func newOutbox() *outbox {
+	return &outbox{
+		messages: 	make(map[int64]*outboxMessage),
+		mtx: 		new(sync.Mutex),
+	}
+}
+
+// sendReliable sends a copy of the packet with a new message id and keeps it until
+// the client acknowledges it, returns the message id or zero if the outbox is full
+func (client *Client) sendReliable(namespace *Namespace, packet *transport.Packet) int64 {
+	copied := *packet
+	conf := client.server.reliableConf()
+
+	outbox := client.outbox
+	outbox.mtx.Lock()
+	if pending := len(outbox.messages); pending >= maxPending(conf) {
+		outbox.mtx.Unlock()
+		client.logger.WithFields(Fields{NamespaceField: packet.Endpoint, EventField: packet.Name}).
+			Warnf("Reliable message dropped, %d messages are not acknowledged", pending)
+		return 0
+	}
+	outbox.lastId++
+	copied.Id = outbox.lastId
+	outbox.messages[copied.Id] = &outboxMessage{
+		packet: 	&copied,
+		attempts: 	1,
+		sentAt: 	time.Now(),
+	}
+	client.scheduleRetry(time.Duration(conf.RetryInterval))
+	outbox.mtx.Unlock()
+
+	client.sendPacket(namespace, &copied)
+	return copied.Id
+}
+
+// must be called with the outbox lock held
+func (client *Client) scheduleRetry(interval time.Duration) {
+	outbox := client.outbox
+	if outbox.retry != nil {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(interval, func() {
+		outbox.mtx.Lock()
+		// the timer was stopped or the outbox was handed over to another client
+		if outbox.retry != timer {
+			outbox.mtx.Unlock()
+			return
+		}
+		outbox.retry = nil
+		outbox.mtx.Unlock()
+		client.resend("")
+	})
+	outbox.retry = timer
+}
+
+// sends the messages which were not acknowledged within the retry interval, or all the
+// pending messages of the namespace the client connected to when the name is given,
+// messages of namespaces the client isn't connected to wait until it connects
+func (client *Client) resend(namespaceName string) {
+	if !client.isOpen() {
+		return
+	}
+	conf := client.server.reliableConf()
+	interval := time.Duration(conf.RetryInterval)
+	now := time.Now()
+
+	outbox := client.outbox
+	outbox.mtx.Lock()
+	due := make([]*transport.Packet, 0)
+	for id, message := range outbox.messages {
+		if namespaceName != "" && message.packet.Endpoint != namespaceName {
+			continue
+		}
+		if namespaceName == "" && now.Sub(message.sentAt) < interval {
+			continue
+		}
+		if client.getNamespace(message.packet.Endpoint) == nil {
+			continue
+		}
+		if conf.MaxAttempts > 0 && message.attempts >= conf.MaxAttempts {
+			delete(outbox.messages, id)
+			client.logger.WithFields(Fields{NamespaceField: message.packet.Endpoint, EventField: message.packet.Name}).
+				Warnf("Reliable message %d was not acknowledged after %d attempts", id, message.attempts)
+			continue
+		}
+		message.attempts++
+		message.sentAt = now
+		due = append(due, message.packet)
+	}
+	if len(outbox.messages) > 0 {
+		client.scheduleRetry(interval)
+	}
+	outbox.mtx.Unlock()
+
+	for _, packet := range due {
+		client.sendPacket(client.getNamespace(packet.Endpoint), packet)
+	}
+}
+
+// removes the acknowledged message from the outbox
+func (client *Client) acknowledge(id int64) {
+	outbox := client.outbox
+	outbox.mtx.Lock()
+	defer outbox.mtx.Unlock()
+	delete(outbox.messages, id)
+	if len(outbox.messages) == 0 && outbox.retry != nil {
+		outbox.retry.Stop()
+		outbox.retry = nil
+	}
+}
+
+// keeps the outbox of a closed client with pending messages for the retention duration
+func (server *Server) parkOutbox(client *Client) {
+	retention := time.Duration(server.reliableConf().Retention)
+	outbox := client.outbox
+	outbox.mtx.Lock()
+	defer outbox.mtx.Unlock()
+	if outbox.retry != nil {
+		outbox.retry.Stop()
+		outbox.retry = nil
+	}
+	if len(outbox.messages) == 0 || retention == 0 {
+		return
+	}
+	outbox.userId = client.UserId()
+
+	server.outboxMtx.Lock()
+	server.outboxes[client.uuid] = outbox
+	server.outboxMtx.Unlock()
+	outbox.expiry = time.AfterFunc(retention, func() {
+		server.outboxMtx.Lock()
+		if server.outboxes[client.uuid] == outbox {
+			delete(server.outboxes, client.uuid)
+		}
+		server.outboxMtx.Unlock()
+	})
+}
+
+// resumes the previous session of a new client if the resume hook accepts it, otherwise
+// the session is kept for the client to claim once it is bound to a user
+func (server *Server) resumeOutbox(client *Client, sessionId string) bool {
+	if authorize := server.reliableConf().AuthorizeResume; authorize != nil && authorize(client, sessionId) {
+		return server.claimOutbox(client, sessionId, "")
+	}
+	client.mtx.Lock()
+	client.resumeId = sessionId
+	client.mtx.Unlock()
+	return false
+}
+
+// moves the messages of the parked outbox of the session to the client, the outbox is
+// claimed only by a client of the same user unless the user id is empty, and only if
+// the client didn't send reliable messages yet since their ids would collide
+func (server *Server) claimOutbox(client *Client, sessionId string, userId string) bool {
+	outbox := client.outbox
+	outbox.mtx.Lock()
+	defer outbox.mtx.Unlock()
+	if outbox.lastId != 0 {
+		return false
+	}
+
+	server.outboxMtx.Lock()
+	parked, ok := server.outboxes[sessionId]
+	if !ok || (userId != "" && parked.userId != userId) {
+		server.outboxMtx.Unlock()
+		return false
+	}
+	delete(server.outboxes, sessionId)
+	server.outboxMtx.Unlock()
+
+	parked.mtx.Lock()
+	parked.expiry.Stop()
+	outbox.lastId = parked.lastId
+	outbox.messages = parked.messages
+	parked.mtx.Unlock()
+	// the messages are due as soon as the client connects to their namespaces
+	for _, message := range outbox.messages {
+		message.sentAt = time.Time{}
+	}
+	client.scheduleRetry(time.Duration(server.reliableConf().RetryInterval))
+	client.logger.Infof("Resumed reliable messages of session %s", sessionId)
+	return true
+}
+
+// sends a copy of the packet to every client as a reliable message
+func (namespace *Namespace) emitReliable(ctx context.Context, clients []*Client, packet *transport.Packet) {
+	tracer := namespace.server.tracer()
+	ctx, span := tracer.Start(ctx, tracing.EmitSpan)
+	defer span.End()
+	span.SetAttribute(tracing.NamespaceKey, namespace.name)
+	span.SetAttribute(tracing.EventKey, packet.Name)
+	span.SetAttribute(tracing.RecipientsKey, len(clients))
+
+	injectTrace(tracer, ctx, packet)
+	for _, client := range clients {
+		client.sendReliable(namespace, packet)
+	}
+}
+
+// EmitReliable sends the event at least once, it is sent again until the client
+// acknowledges its id, clients must ignore ids they have already received
+func (client *SocketClient) EmitReliable(event string, args ...interface{}) {
+	client.namespace.emitReliable(client.Context(), []*Client{client.Client}, eventPacket(event, client.namespace, args))
+}
+
+// EmitReliable sends the event at least once to all clients of the namespace
+func (namespace *Namespace) EmitReliable(event string, args ...interface{}) {
+	namespace.emitReliable(namespace.server.Context(), namespace.clientList(), eventPacket(event, namespace, args))
+}
+
+// EmitReliable sends the event at least once to all clients in the room
+func (room *Room) EmitReliable(event string, args ...interface{}) {
+	packet := eventPacket(event, room.namespace, args)
+	room.record(packet)
+	room.namespace.emitReliable(room.namespace.server.Context(), room.clientList(), packet)
+}
@@ -0,0 +1,91 @@
+package socket
+
+import (
+	"testing"
+	"time"
+)
+
+func pendingMessages(client *Client) int {
+	client.outbox.mtx.Lock()
+	defer client.outbox.mtx.Unlock()
+	return len(client.outbox.messages)
+}
+
+// opens a client of the user with unacknowledged messages and disconnects it
+func parkedSession(t *testing.T, server *Server, userId string) string {
+	client := openTestClient(t, server, "/")
+	client.SetUser(userId)
+	for i := 0; i < 3; i++ {
+		client.sendReliable(server.Namespace, eventPacket("tick", server.Namespace, []interface{}{i}))
+	}
+	client.Disconnect()
+	return client.uuid
+}
+
+func TestResumeRequiresSameUser(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	sessionId := parkedSession(t, server, "alice")
+
+	anonymous := openTestClient(t, server, "/?" + ResumeParam + "=" + sessionId)
+	if pendingMessages(anonymous) != 0 {
+		t.Fatal("Session was resumed without authentication")
+	}
+	anonymous.SetUser("mallory")
+	if pendingMessages(anonymous) != 0 {
+		t.Fatal("Session was resumed by another user")
+	}
+
+	resumed := openTestClient(t, server, "/?" + ResumeParam + "=" + sessionId)
+	resumed.SetUser("alice")
+	if count := pendingMessages(resumed); count != 3 {
+		t.Fatalf("Expected 3 resumed messages, got %d", count)
+	}
+	if id := resumed.sendReliable(server.Namespace, eventPacket("tick", server.Namespace, nil)); id != 4 {
+		t.Fatalf("Expected the message ids to continue, got %d", id)
+	}
+}
+
+func TestResumeHook(t *testing.T) {
+	conf := DefaultConf()
+	conf.Reliable = DefaultReliableConf()
+	conf.Reliable.AuthorizeResume = func(client *Client, sessionId string) bool {
+		return client.Handshake().Query.Get("token") == "secret"
+	}
+	server := newTestServer(t, conf)
+	defer server.Stop()
+	sessionId := parkedSession(t, server, "")
+
+	rejected := openTestClient(t, server, "/?token=wrong&" + ResumeParam + "=" + sessionId)
+	if pendingMessages(rejected) != 0 {
+		t.Fatal("Session was resumed with a rejected token")
+	}
+	resumed := openTestClient(t, server, "/?token=secret&" + ResumeParam + "=" + sessionId)
+	if count := pendingMessages(resumed); count != 3 {
+		t.Fatalf("Expected 3 resumed messages, got %d", count)
+	}
+}
+
+func TestOutboxLimit(t *testing.T) {
+	conf := DefaultConf()
+	conf.Reliable = DefaultReliableConf()
+	conf.Reliable.MaxAttempts = 0
+	conf.Reliable.MaxPending = 2
+	conf.Reliable.RetryInterval = Duration(time.Hour)
+	server := newTestServer(t, conf)
+	defer server.Stop()
+
+	client := openTestClient(t, server, "/")
+	for i := 0; i < 2; i++ {
+		if id := client.sendReliable(server.Namespace, eventPacket("tick", server.Namespace, nil)); id == 0 {
+			t.Fatal("Expected the message to be accepted")
+		}
+	}
+	if id := client.sendReliable(server.Namespace, eventPacket("tick", server.Namespace, nil)); id != 0 {
+		t.Fatalf("Expected the message over the limit to be dropped, got id %d", id)
+	}
+	client.acknowledge(1)
+	if id := client.sendReliable(server.Namespace, eventPacket("tick", server.Namespace, nil)); id != 3 {
+		t.Fatalf("Expected an acknowledgment to free a slot, got id %d", id)
+	}
+}
@@ -0,0 +1,134 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+	"github.com/ppincak/gse/socket/transport/socketio"
+	"github.com/ppincak/gse/utils"
+)
+
+// query parameter carrying the session id of a long-polling client
+const SessionParam = "sid"
+
+var errUnknownSession = errors.New("Unknown session")
+
+// first polling response of the gse protocol
+type pollingOpen struct {
+	Sid 		string 		`json:"sid"`
+	Upgrades 	[]string 	`json:"upgrades"`
+}
+
+// ServePolling serves long-polling clients, a GET without the sid query parameter opens
+// a new session and answers with its id, GET requests with the sid receive the queued
+// packets and POST requests send packets, OPTIONS answers the preflight of cross-origin requests
+func (server *Server) ServePolling(w http.ResponseWriter, r *http.Request) {
+	server.confMtx.RLock()
+	conf, upgrader := server.conf, server.upgrader
+	server.confMtx.RUnlock()
+
+	if !checkOrigin(upgrader, r) {
+		server.logger.WithFields(Fields{AddressField: r.RemoteAddr}).Warnf("Rejected polling request: origin not allowed")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if !server.admitSecure(w, r, conf) {
+		return
+	}
+	for key, value := range conf.ResponseHeaders {
+		w.Header().Set(key, value)
+	}
+	allowOrigin(w, r, conf)
+	if r.Method == http.MethodOptions {
+		allowPreflight(w, r)
+		return
+	}
+
+	sid := r.URL.Query().Get(SessionParam)
+	if sid == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if !server.admitClient(w, r, conf) {
+			return
+		}
+		conn := transport.NewPolling(transport.PollingConf{
+			PollTimeout: 	pollTimeout(conf),
+			MaxPayload: 	maxPayload(conf),
+			Keepalive: 		pollingKeepalive(conf),
+		})
+		client := server.openClient(r, conf, utils.GenerateUID(), conn)
+		transport.WritePayload(w, []transport.Frame{server.openFrame(conf, client, []string{transport.WebSocketTransport})})
+		go client.readPump(conn)
+		return
+	}
+
+	client := server.pollingClient(sid)
+	if client == nil {
+		http.Error(w, errUnknownSession.Error(), http.StatusBadRequest)
+		return
+	}
+	polling, ok := client.getTransport().(*transport.Polling)
+	if !ok {
+		http.Error(w, errUnknownSession.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+		case http.MethodGet:
+			polling.ServePoll(w, r)
+		case http.MethodPost:
+			polling.ServeSend(w, r)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// returns the open client with the session id if it uses the long-polling transport
+func (server *Server) pollingClient(sid string) *Client {
+	client := server.GetSession(sid)
+	if client == nil || client.Transport() != transport.PollingTransport {
+		return nil
+	}
+	return client
+}
+
+// returns the frame which tells the client its session id and the available upgrades
+func (server *Server) openFrame(conf *ServerConf, client *Client, upgrades []string) transport.Frame {
+	if conf.Protocol == SocketIOProtocol {
+		interval, timeout := client.heartbeat.durations()
+		return socketio.OpenFrame(client.uuid, upgrades, interval, timeout, maxPayload(conf))
+	}
+	if upgrades == nil {
+		upgrades = []string{}
+	}
+	raw, _ := json.Marshal(pollingOpen{
+		Sid: 		client.uuid,
+		Upgrades: 	upgrades,
+	})
+	return transport.Frame{Data: raw}
+}
+
+func maxPayload(conf *ServerConf) int64 {
+	if conf.MaxMessageSize > 0 {
+		return conf.MaxMessageSize
+	}
+	return MaxPayload
+}
+
+func pollTimeout(conf *ServerConf) time.Duration {
+	if conf.PollTimeout > 0 {
+		return time.Duration(conf.PollTimeout)
+	}
+	return PollTimeout
+}
+
+func pollingKeepalive(conf *ServerConf) *transport.Frame {
+	if conf.Protocol == SocketIOProtocol {
+		noop := socketio.NoopFrame()
+		return &noop
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package socket
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// run with -race, the tests exercise the registries from many goroutines
+// and check that the indexes agree afterwards
+
+func TestConcurrentJoinLeaveEmit(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	namespace, err := server.AddNamespace("/stress", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		namespace.AddRoom(fmt.Sprintf("room.%d", i))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := openTestClient(t, server, "/")
+			socketClient := connectTestClient(t, client, "/stress")
+			for j := 0; j < 50; j++ {
+				roomName := fmt.Sprintf("room.%d", (i + j) % 5)
+				socketClient.JoinRoom(roomName)
+				if room, err := namespace.GetRoom(roomName); err == nil {
+					room.SendEvent("tick", j)
+				}
+				namespace.EmitTo("room", "all", j)
+				if j % 3 == 0 {
+					socketClient.LeaveRoom(roomName)
+				}
+				client.GetAllRooms()
+				namespace.GetClients()
+			}
+			if i % 2 == 0 {
+				client.Disconnect()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assertRoomIndexes(t, namespace)
+	for _, client := range namespace.GetClients() {
+		if !client.isOpen() {
+			t.Errorf("Closed client %s is still in the namespace", client.uuid)
+		}
+	}
+}
+
+func TestConcurrentAddRemoveRoom(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	namespace, err := server.AddNamespace("/stress", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := make([]*SocketClient, 10)
+	for i := range clients {
+		clients[i] = connectTestClient(t, openTestClient(t, server, "/"), "/stress")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				roomName := fmt.Sprintf("org.%d.team.%d", j % 3, i % 4)
+				namespace.AddRoom(roomName)
+				if j % 2 == 0 {
+					namespace.RemoveRoom(roomName)
+				}
+			}
+		}(i)
+		go func(client *SocketClient) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.JoinRoom(fmt.Sprintf("org.%d.team.%d", j % 3, j % 4))
+				namespace.MatchRooms("org.*")
+			}
+		}(clients[i])
+	}
+	wg.Wait()
+
+	assertRoomIndexes(t, namespace)
+	if rooms, count := len(namespace.GetRooms()), atomic.LoadInt32(&server.numOfRooms); int32(rooms) != count {
+		t.Errorf("Expected %d reserved rooms, got %d", rooms, count)
+	}
+	for _, room := range namespace.GetRooms() {
+		if len(namespace.MatchRooms(room.name)) == 0 {
+			t.Errorf("Room %s is missing from the pattern index", room.name)
+		}
+	}
+}
+
+func TestConcurrentSetUser(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	clients := make([]*Client, 20)
+	for i := range clients {
+		clients[i] = openTestClient(t, server, "/")
+	}
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(2)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.SetUser(fmt.Sprintf("user.%d", (i + j) % 3))
+			}
+		}(i, client)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.SetUser(fmt.Sprintf("user.%d", j % 4))
+			}
+			if i % 2 == 0 {
+				client.Disconnect()
+			}
+		}(i, client)
+	}
+	wg.Wait()
+
+	sessions := 0
+	server.usersMtx.RLock()
+	for userId, users := range server.users {
+		for _, client := range users {
+			sessions++
+			if !client.isOpen() {
+				t.Errorf("Closed client %s is bound to %s", client.uuid, userId)
+			}
+			if client.UserId() != userId {
+				t.Errorf("Client %s of %s is indexed under %s", client.uuid, client.UserId(), userId)
+			}
+		}
+	}
+	server.usersMtx.RUnlock()
+	if sessions != len(clients) / 2 {
+		t.Errorf("Expected %d bound sessions, got %d", len(clients) / 2, sessions)
+	}
+}
+
+func TestConnectAfterDisconnect(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	namespace, err := server.AddNamespace("/closed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := openTestClient(t, server, "/")
+	client.Disconnect()
+
+	if err := namespace.addClient(client); err != errClientClosed {
+		t.Fatalf("Expected %v, got %v", errClientClosed, err)
+	}
+	if namespace.GetClient(client.uuid) != nil {
+		t.Fatal("Closed client was added to the namespace")
+	}
+	if server.addClient(client) {
+		t.Fatal("Closed client was added to the server")
+	}
+}
@@ -2,6 +2,7 @@ package socket
 
 import (
 	"github.com/ppincak/gse/utils"
+	"github.com/ppincak/gse/socket/transport"
 	"sync"
 )
 
@@ -13,6 +14,10 @@ type Room struct {
 	namespace	*Namespace
 	// all the clients in the room
 	clients 	map[string]*Client
+	// recorded events, nil if the room doesn't keep history
+	history 	HistoryStore
+	// flag indicating that the room was destroyed and can't be joined
+	destroyed 	bool
 	// room lock
 	mtx     	*sync.RWMutex
 }
@@ -35,10 +40,15 @@ func (room *Room) GetName() string {
 	return room.name
 }
 
-func (room *Room) addClient(client *Client) {
+// adds the client unless the room was destroyed
+func (room *Room) addClient(client *Client) bool {
 	room.mtx.Lock()
+	defer room.mtx.Unlock()
+	if room.destroyed {
+		return false
+	}
 	room.clients[client.uuid] = client
-	room.mtx.Unlock()
+	return true
 }
 
 func (room *Room) removeClient(client *Client) {
@@ -48,12 +58,15 @@ func (room *Room) removeClient(client *Client) {
 }
 
 func (room *Room) GetClients() []*Client {
+	return room.clientList()
+}
+
+func (room *Room) clientList() []*Client {
 	room.mtx.RLock()
 	defer room.mtx.RUnlock()
-	clients := make([]*Client, len(room.clients))
-	i := 0
+	clients := make([]*Client, 0, len(room.clients))
 	for _, client := range room.clients {
-		clients[i] = client
+		clients = append(clients, client)
 	}
 	return clients
 }
@@ -65,18 +78,34 @@ func (room *Room) HasClient(sessionId string) bool {
 	return contains
 }
 
+// Destroy removes all the clients from the room and closes its history,
+// the room can't be joined afterwards
 func (room *Room) Destroy() {
-	for _, client := range room.clients {
-		client.leaveRoom(room)
-	}
-
 	room.mtx.Lock()
+	clients := room.clients
 	room.clients = make(map[string] *Client);
+	room.destroyed = true
+	history := room.history
+	room.history = nil
 	room.mtx.Unlock()
+
+	closeHistory(history)
+
+	for _, client := range clients {
+		client.forgetRoom(room)
+	}
 }
 
 func (room *Room) SendEvent(event string, data interface{}) {
-	for _, client := range room.clients {
-		client.sendEvent(event, data, room.namespace.name)
-	}
+	room.send(&transport.Packet{
+		Name: 		event,
+		Data: 		data,
+		PacketType: transport.Event,
+		Endpoint: 	room.namespace.name,
+	})
+}
+
+func (room *Room) send(packet *transport.Packet) {
+	room.record(packet)
+	room.namespace.emit(room.namespace.server.Context(), room.clientList(), packet)
 }
\ No newline at end of file
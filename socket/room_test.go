@@ -0,0 +1,153 @@
+package socket
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLeaveRoomOfAllNamespaces(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	client := openTestClient(t, server, "/")
+	rooms := make([]*Room, 0)
+	for _, namespaceName := range []string{"/a", "/b"} {
+		namespace, err := server.AddNamespace(namespaceName, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		room, err := namespace.AddRoom("lobby")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := connectTestClient(t, client, namespaceName).JoinRoom("lobby"); err != nil {
+			t.Fatal(err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	client.wrap(client.getNamespace("/a")).LeaveRoom("lobby")
+	if rooms[0].HasClient(client.uuid) || !rooms[1].HasClient(client.uuid) {
+		t.Fatal("Expected only the room of /a to be left")
+	}
+	if !client.InRoom("lobby") {
+		t.Fatal("Expected the client to stay in the room of /b")
+	}
+
+	client.wrap(client.getNamespace("/a")).JoinRoom("lobby")
+	client.LeaveRoom("lobby")
+	for _, room := range rooms {
+		if room.HasClient(client.uuid) {
+			t.Fatalf("Client is still in the room of %s", room.namespace.name)
+		}
+	}
+	if client.InRoom("lobby") {
+		t.Fatal("Expected the client to leave the rooms of all namespaces")
+	}
+}
+
+// fills the namespace up to the room limit with hierarchical names, returns the rooms
+func fillRooms(b *testing.B, server *Server, namespace *Namespace) []*Room {
+	max := int(server.config().MaxNumOfRooms)
+	rooms := make([]*Room, 0, max)
+	for i := 0; i < max; i++ {
+		room, err := namespace.AddRoom(fmt.Sprintf("org.%d.team.%d", i / 50, i % 50))
+		if err != nil {
+			b.Fatal(err)
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func benchmarkNamespace(b *testing.B) (*Server, *Namespace) {
+	server := newTestServer(b, nil)
+	namespace, err := server.AddNamespace("/bench", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return server, namespace
+}
+
+func BenchmarkAddRoom(b *testing.B) {
+	server, namespace := benchmarkNamespace(b)
+	defer server.Stop()
+	rooms := fillRooms(b, server, namespace)
+	// keep a free slot so the benchmark measures insertion into a full index
+	namespace.RemoveRoom(rooms[len(rooms) - 1].name)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := namespace.AddRoom("org.bench.team.bench"); err != nil {
+			b.Fatal(err)
+		}
+		namespace.RemoveRoom("org.bench.team.bench")
+	}
+}
+
+func BenchmarkGetRoom(b *testing.B) {
+	server, namespace := benchmarkNamespace(b)
+	defer server.Stop()
+	rooms := fillRooms(b, server, namespace)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := namespace.GetRoom(rooms[i % len(rooms)].name); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetRoomById(b *testing.B) {
+	server, namespace := benchmarkNamespace(b)
+	defer server.Stop()
+	rooms := fillRooms(b, server, namespace)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := namespace.GetRoomById(rooms[i % len(rooms)].uuid); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEmitToPattern(b *testing.B) {
+	server, namespace := benchmarkNamespace(b)
+	defer server.Stop()
+	fillRooms(b, server, namespace)
+	// 100 clients spread over the 50 teams of one organization
+	for i := 0; i < 100; i++ {
+		client := connectTestClient(b, openTestClient(b, server, "/"), namespace.name)
+		if err := client.JoinRoom(fmt.Sprintf("org.7.team.%d", i % 50)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		namespace.EmitTo("org.7.*", "tick", i)
+	}
+}
+
+func TestAddRoomValidatesName(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	for _, name := range []string{"", "a..b", "a.", ".a", "*", "org.*", "org.*.team"} {
+		_, err := server.AddRoom(name)
+		if e, ok := err.(Error); !ok || e.ErrorCode != InvalidRoomName {
+			t.Errorf("Expected %q to be rejected, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"lobby", "org.42.team.7", "a-b_c"} {
+		if _, err := server.AddRoom(name); err != nil {
+			t.Errorf("Expected %q to be accepted, got %v", name, err)
+		}
+	}
+	if count := atomic.LoadInt32(&server.numOfRooms); count != 3 {
+		t.Fatalf("Expected rejected names not to reserve rooms, got %d", count)
+	}
+}
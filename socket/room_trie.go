@@ -0,0 +1,179 @@
+package socket
+
+import (
+	"errors"
+	"strings"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+const(
+	// separates the levels of hierarchical room names, e.g. "org.42.team.7"
+	RoomSeparator 	= "."
+	// matches any single level of a room pattern
+	RoomWildcard 	= "*"
+)
+
+type roomNode struct {
+	// child levels by name
+	children 	map[string]*roomNode
+	// room with the name of the node path, nil if the level is only a prefix
+	room 		*Room
+}
+
+func newRoomNode() *roomNode {
+	return &roomNode{
+		children: make(map[string]*roomNode),
+	}
+}
+
+// roomTrie indexes the rooms of a namespace by the levels of their names for pattern
+// queries, it is guarded by the namespace lock
+type roomTrie struct {
+	root 	*roomNode
+}
+
+func newRoomTrie() *roomTrie {
+	return &roomTrie{
+		root: newRoomNode(),
+	}
+}
+
+func splitRoomName(name string) []string {
+	return strings.Split(name, RoomSeparator)
+}
+
+// room names consist of non empty levels, the wildcard is reserved for patterns
+func validateRoomName(name string) error {
+	for _, level := range splitRoomName(name) {
+		switch level {
+			case "":
+				return makeComplexError(InvalidRoomName, errors.New("empty level in " + name))
+			case RoomWildcard:
+				return makeComplexError(InvalidRoomName, errors.New("wildcard level in " + name))
+		}
+	}
+	return nil
+}
+
+// adds the room, returns false if a room with the name exists
+func (trie *roomTrie) insert(room *Room) bool {
+	node := trie.root
+	for _, level := range splitRoomName(room.name) {
+		child, ok := node.children[level]
+		if !ok {
+			child = newRoomNode()
+			node.children[level] = child
+		}
+		node = child
+	}
+	if node.room != nil {
+		return false
+	}
+	node.room = room
+	return true
+}
+
+// removes the room with the name and the levels left without rooms, returns the removed room
+func (trie *roomTrie) remove(name string) *Room {
+	levels := splitRoomName(name)
+	path := make([]*roomNode, 0, len(levels) + 1)
+	node := trie.root
+	for _, level := range levels {
+		path = append(path, node)
+		if node = node.children[level]; node == nil {
+			return nil
+		}
+	}
+	room := node.room
+	if room == nil {
+		return nil
+	}
+	node.room = nil
+	for i := len(levels) - 1; i >= 0 && node.room == nil && len(node.children) == 0; i-- {
+		delete(path[i].children, levels[i])
+		node = path[i]
+	}
+	return room
+}
+
+// returns the rooms matching the pattern and all their descendants, the levels of
+// the pattern are names or the wildcard matching any single level
+func (trie *roomTrie) match(pattern string) []*Room {
+	rooms := make([]*Room, 0)
+	trie.root.match(splitRoomName(pattern), &rooms)
+	return rooms
+}
+
+func (node *roomNode) match(levels []string, rooms *[]*Room) {
+	if len(levels) == 0 {
+		node.collect(rooms)
+		return
+	}
+	if levels[0] == RoomWildcard {
+		for _, child := range node.children {
+			child.match(levels[1:], rooms)
+		}
+		return
+	}
+	if child, ok := node.children[levels[0]]; ok {
+		child.match(levels[1:], rooms)
+	}
+}
+
+func (node *roomNode) collect(rooms *[]*Room) {
+	if node.room != nil {
+		*rooms = append(*rooms, node.room)
+	}
+	for _, child := range node.children {
+		child.collect(rooms)
+	}
+}
+
+// MatchRooms returns the rooms matching the pattern and their descendants, e.g. "org.42"
+// matches "org.42" and "org.42.team.7", "org.*.team" matches the teams of all organizations
+func (namespace *Namespace) MatchRooms(pattern string) []*Room {
+	namespace.mtx.RLock()
+	defer namespace.mtx.RUnlock()
+	return namespace.rooms.match(pattern)
+}
+
+// GetClientsIn returns the clients in any of the rooms matching the pattern, each client once
+func (namespace *Namespace) GetClientsIn(pattern string) []*Client {
+	seen := make(map[string]bool)
+	clients := make([]*Client, 0)
+	for _, room := range namespace.MatchRooms(pattern) {
+		for _, client := range room.clientList() {
+			if !seen[client.uuid] {
+				seen[client.uuid] = true
+				clients = append(clients, client)
+			}
+		}
+	}
+	return clients
+}
+
+// IsClientIn checks if the client is in any of the rooms matching the pattern
+func (namespace *Namespace) IsClientIn(pattern string, sessionId string) bool {
+	for _, room := range namespace.MatchRooms(pattern) {
+		if room.HasClient(sessionId) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmitTo sends the event with positional arguments to the clients in the rooms matching
+// the pattern, every client receives it once, the event isn't recorded in the room history
+func (namespace *Namespace) EmitTo(pattern string, event string, args ...interface{}) {
+	namespace.emit(namespace.server.Context(), namespace.GetClientsIn(pattern), eventPacket(event, namespace, args))
+}
+
+// SendEventTo sends the event to the clients in the rooms matching the pattern
+func (namespace *Namespace) SendEventTo(pattern string, event string, data interface{}) {
+	namespace.emit(namespace.server.Context(), namespace.GetClientsIn(pattern), &transport.Packet{
+		Name: 		event,
+		Data: 		data,
+		PacketType: transport.Event,
+		Endpoint: 	namespace.name,
+	})
+}
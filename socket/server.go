@@ -1,12 +1,19 @@
 package socket
 
 import (
+	"context"
 	"github.com/gorilla/websocket"
-	"github.com/sirupsen/logrus"
 	"github.com/ppincak/gse/store"
+	"github.com/ppincak/gse/utils"
 	"net/http"
 	"errors"
+	"sync"
+	"time"
+	"sync/atomic"
 	"github.com/ppincak/gse/socket/stats"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+	"github.com/ppincak/gse/socket/transport/socketio"
 )
 
 type Server struct {
@@ -14,16 +21,49 @@ type Server struct {
 	*Namespace
 	// map of all namespaces
 	namespaces		map[string]*Namespace
+	// namespaces created on demand
+	dynamic 		[]*DynamicNamespace
+	// namespace registry lock
+	nsMtx 			*sync.RWMutex
+	// base context of the client contexts, cancelled when the server is stopped
+	ctx 			context.Context
+	cancel 			context.CancelFunc
 	// gorilla websocket upgrader
 	upgrader   		*websocket.Upgrader
 	// server configuration
 	conf         	*ServerConf
+	// configuration lock, guards conf and upgrader
+	confMtx 		*sync.RWMutex
 	// store factory
 	storeFactory 	socket.StoreFactory
 	// server stats
 	stats			*stats.Stats
 	// flag indicating that the server is running
 	isRunning		bool
+	// guards isRunning, serializes Run and Stop
+	runMtx 			*sync.Mutex
+	// number of rooms in all namespaces
+	numOfRooms 		int32
+	// number of open and admitted sessions
+	numOfClients 	int32
+	// logger with the server field
+	logger 			Logger
+	// middleware applied to all namespaces
+	middleware 		[]Middleware
+	// middleware lock
+	middlewareMtx 	*sync.RWMutex
+	// sessions of the bound users by user id
+	users 			map[string]map[string]*Client
+	// user index lock
+	usersMtx 		*sync.RWMutex
+	// open transport sessions by session id, independent of the namespaces the clients are in
+	sessions 		map[string]*Client
+	// session registry lock
+	sessionsMtx 	*sync.RWMutex
+	// outboxes of disconnected clients waiting for a reconnect by session id
+	outboxes 		map[string]*outbox
+	// outbox registry lock
+	outboxMtx 		*sync.Mutex
 }
 
 func NewServer(storeFactory socket.StoreFactory, config *ServerConf) *Server {
@@ -33,68 +73,306 @@ func NewServer(storeFactory socket.StoreFactory, config *ServerConf) *Server {
 	if config == nil {
 		config = DefaultConf()
 	}
-
-	upgrader := &websocket.Upgrader{
-		ReadBufferSize: 	config.ReadBufferSize,
-		WriteBufferSize: 	config.WriteBufferSize,
+	logger := config.Logger
+	if logger == nil {
+		logger = NoopLogger()
 	}
 
 	server := &Server {
-		upgrader: 		upgrader,
+		upgrader: 		newUpgrader(config),
 		namespaces:     make(map[string] *Namespace),
 		storeFactory: 	storeFactory,
 		conf: 			config,
+		confMtx: 		new(sync.RWMutex),
+		nsMtx: 			new(sync.RWMutex),
+		middlewareMtx: 	new(sync.RWMutex),
+		runMtx: 		new(sync.Mutex),
+		users: 			make(map[string]map[string]*Client),
+		usersMtx: 		new(sync.RWMutex),
+		sessions: 		make(map[string]*Client),
+		sessionsMtx: 	new(sync.RWMutex),
+		outboxes: 		make(map[string]*outbox),
+		outboxMtx: 		new(sync.Mutex),
 		stats:          stats.NewStats(),
+		logger: 		logger.WithFields(Fields{ServerField: config.ServerName}),
 	}
+	server.ctx, server.cancel = context.WithCancel(baseContext(config))
 	server.Namespace = rootNamespace(server)
 	return server
 }
 
-// warning: Thread unsafe
+// Run starts the root namespace routine, calling it on a running server has no effect
 func (server *Server) Run() {
-	go server.Namespace.Run()
+	server.runMtx.Lock()
+	defer server.runMtx.Unlock()
+	if server.isRunning {
+		return
+	}
+	server.logger.Infof("Starting server")
+	server.confMtx.Lock()
+	if server.ctx.Err() != nil {
+		server.ctx, server.cancel = context.WithCancel(baseContext(server.conf))
+	}
+	server.confMtx.Unlock()
+	server.Namespace.start()
 	server.isRunning = true
-	server.stats.Run()
 }
 
-// warning: Thread unsafe
+// Stop cancels the server context and stops the root namespace routine,
+// calling it on a stopped server has no effect
 func (server *Server) Stop() {
+	server.runMtx.Lock()
+	defer server.runMtx.Unlock()
+	if !server.isRunning {
+		return
+	}
+	server.logger.Infof("Stopping server")
+	server.confMtx.RLock()
+	server.cancel()
+	server.confMtx.RUnlock()
 	server.Namespace.Stop()
 	server.isRunning = false
-	server.stats.Stop()
 }
 
-func(server *Server) Stats(c chan<- stats.Stats) {
+func (server *Server) IsRunning() bool {
+	server.runMtx.Lock()
+	defer server.runMtx.Unlock()
+	return server.isRunning
+}
+
+func (server *Server) config() *ServerConf {
+	server.confMtx.RLock()
+	defer server.confMtx.RUnlock()
+	return server.conf
+}
+
+// Reload applies a new configuration to a running server.
+// serverName, eventBuffer and protocol can't be changed at runtime and keep their current values,
+// upgrader settings and limits apply to new connections, rate limits to connected clients as well.
+func (server *Server) Reload(config *ServerConf) error {
+	if config == nil {
+		return errors.New("Configuration must not be nil")
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	server.confMtx.Lock()
+	defer server.confMtx.Unlock()
+	reloaded := *config
+	reloaded.ServerName = server.conf.ServerName
+	reloaded.EventBufferSize = server.conf.EventBufferSize
+	// connected clients keep the codec they were opened with
+	reloaded.Protocol = server.conf.Protocol
+	if reloaded.UpgraderHook == nil {
+		reloaded.UpgraderHook = server.conf.UpgraderHook
+	}
+	if reloaded.Tracer == nil {
+		reloaded.Tracer = server.conf.Tracer
+	}
+	// the logger and the base context are bound when the server is created
+	reloaded.Logger = server.conf.Logger
+	reloaded.BaseContext = server.conf.BaseContext
+	server.conf = &reloaded
+	server.upgrader = newUpgrader(&reloaded)
+	server.logger.Infof("Configuration reloaded")
+	return nil
+}
+
+func (server *Server) ReloadFile(confPath string) error {
+	config, err := LoadConf(confPath)
+	if err != nil {
+		return err
+	}
+	return server.Reload(config)
+}
+
+// GetStats returns a copy of the server counters
+func (server *Server) GetStats() stats.Stats {
+	return server.stats.Clone()
+}
+
+// Stats sends a copy of the server counters to the channel without blocking the caller
+//
+// Deprecated: use GetStats which returns the copy directly
+func (server *Server) Stats(c chan<- stats.Stats) {
 	server.stats.Get(c)
 }
 
-func (server *Server) AddNamespace(namespaceName string) (*Namespace, error) {
-	if server.isRunning {
-		return nil, errors.New("Server is already running")
+// AddNamespace registers a namespace, nil configuration uses the defaults
+func (server *Server) AddNamespace(namespaceName string, conf *NamespaceConf) (*Namespace, error) {
+	if conf != nil {
+		if err := conf.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	namespace := newNamespace(namespaceName, server, conf)
+
+	server.nsMtx.Lock()
+	if _, ok := server.namespaces[namespaceName]; ok || namespaceName == server.Namespace.name {
+		server.nsMtx.Unlock()
+		return nil, errors.New("Namespace already exists")
 	}
-	logrus.Infof("Registering namespace: %s ", namespaceName)
-	namespace := newNamespace(namespaceName, server)
 	server.namespaces[namespaceName] = namespace
-	go namespace.Run()
+	server.nsMtx.Unlock()
+
+	namespace.logger.Infof("Registering namespace")
+	namespace.start()
 	return namespace, nil
 }
 
+// RemoveNamespace disconnects all the clients of the namespace and stops its routine
+func (server *Server) RemoveNamespace(namespaceName string) error {
+	server.nsMtx.Lock()
+	namespace, ok := server.namespaces[namespaceName]
+	if ok {
+		delete(server.namespaces, namespaceName)
+	}
+	server.nsMtx.Unlock()
+	if !ok {
+		return errors.New("Namespace doesn't exist")
+	}
+
+	clients, open := namespace.close()
+	if !open {
+		// an empty dynamic namespace removed itself in the meantime
+		return nil
+	}
+	for _, client := range clients {
+		namespace.disconnectClient(client)
+	}
+	namespace.Stop()
+	namespace.logger.Infof("Removed namespace")
+	return nil
+}
+
+// ServeHTTP serves websocket connections and falls back to long-polling for other requests
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		server.ServeWebSocket(w, r)
+		return
+	}
+	server.ServePolling(w, r)
+}
+
+// ServeWebSocket opens a websocket connection, requests with the sid query
+// parameter upgrade the long-polling client with the session id
 func (server *Server) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
-	ws, err := server.upgrader.Upgrade(w, r, nil)
+	server.confMtx.RLock()
+	conf, upgrader := server.conf, server.upgrader
+	server.confMtx.RUnlock()
+
+	tracer := server.tracer()
+	_, span := tracer.Start(tracer.Extract(r.Context(), headerCarrier(r.Header)), tracing.UpgradeSpan)
+	defer span.End()
+	span.SetAttribute(tracing.AddressKey, r.RemoteAddr)
+	span.SetAttribute(tracing.TransportKey, transport.WebSocketTransport)
+
+	if !server.admitSecure(w, r, conf) {
+		span.RecordError(errInsecureConnection)
+		return
+	}
+	var upgraded *Client
+	if sid := r.URL.Query().Get(SessionParam); sid != "" {
+		if upgraded = server.pollingClient(sid); upgraded == nil {
+			span.RecordError(errUnknownSession)
+			http.Error(w, errUnknownSession.Error(), http.StatusBadRequest)
+			return
+		}
+		span.SetAttribute(tracing.SessionKey, sid)
+	} else if !server.admitClient(w, r, conf) {
+		span.RecordError(makeError(MaxClientsReached))
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, responseHeader(conf.ResponseHeaders))
 	if err != nil {
-		logrus.Error(err)
+		if upgraded == nil {
+			server.releaseClient()
+		}
+		span.RecordError(err)
+		server.logger.WithFields(Fields{AddressField: r.RemoteAddr}).Warnf("Upgrade failed: %v", err)
 		return
 	}
+	if conf.MaxMessageSize > 0 {
+		ws.SetReadLimit(conf.MaxMessageSize)
+	}
+	conn := transport.NewWebSocket(ws)
+
+	if upgraded != nil {
+		go upgraded.upgrade(conn, time.Duration(conf.HandshakeTimeout))
+		return
+	}
+	client := server.openClient(r, conf, utils.GenerateUID(), conn)
+	span.SetAttribute(tracing.SessionKey, client.uuid)
+	if conf.Protocol == SocketIOProtocol {
+		client.sendFrames([]transport.Frame{server.openFrame(conf, client, nil)})
+	}
+	go client.readPump(conn)
+}
+
+// ServeTransport opens a client over a custom transport, the request provides the handshake data
+func (server *Server) ServeTransport(conn transport.Transport, r *http.Request) (*Client, error) {
+	conf := server.config()
+	if !server.reserveClient(conf) {
+		server.stats.Inc(stats.ConnectionFailures)
+		return nil, makeError(MaxClientsReached)
+	}
+	client := server.openClient(r, conf, utils.GenerateUID(), conn)
+	go client.readPump(conn)
+	return client, nil
+}
+
+func (server *Server) admitSecure(w http.ResponseWriter, r *http.Request, conf *ServerConf) bool {
+	if conf.RequireTLS && !isSecureRequest(r, conf.TrustForwardedProto) {
+		server.logger.WithFields(Fields{AddressField: r.RemoteAddr}).Warnf("Rejected insecure connection")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+	return true
+}
 
-	client := NewClient(server, ws, server.storeFactory())
+// reserves a client slot for a new session, answers with 503 if the client limit was reached
+func (server *Server) admitClient(w http.ResponseWriter, r *http.Request, conf *ServerConf) bool {
+	if !server.reserveClient(conf) {
+		server.logger.WithFields(Fields{AddressField: r.RemoteAddr}).Warnf("Rejected connection: %s", Errors[MaxClientsReached])
+		server.stats.Inc(stats.ConnectionFailures)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// creates the client of a new connection and starts its write pump
+func (server *Server) openClient(r *http.Request, conf *ServerConf, uuid string, conn transport.Transport) *Client {
+	handshake := newHandshake(r, conf.TrustForwardedProto)
+	handshake.namespaceQuery[server.Namespace.name] = handshake.Query
+	client := newClient(uuid, server, conn, server.storeFactory(), handshake)
+	resumed := false
+	if sessionId := r.URL.Query().Get(ResumeParam); sessionId != "" {
+		resumed = server.resumeOutbox(client, sessionId)
+	}
 	server.addClient(client)
-	logrus.Infof("Client connection established, sessionId: %s", client.GetSessionId())
+	client.logger.WithFields(Fields{AddressField: r.RemoteAddr, TransportField: conn.Name()}).Infof("Client connection established")
 
-	go client.readPump()
 	go client.writePump()
+	if resumed {
+		go client.resend(server.Namespace.name)
+	}
+	return client
+}
+
+// returns the codec of the configured protocol
+func (server *Server) newCodec(conf *ServerConf, sid string) transport.Codec {
+	if conf.Protocol == SocketIOProtocol {
+		return socketio.NewCodec(sid)
+	}
+	return transport.JsonCodec{}
 }
 
 func (server *Server) GetAllNamespaces() []*Namespace {
+	server.nsMtx.RLock()
+	defer server.nsMtx.RUnlock()
 	namespaces := make([]*Namespace, len(server.namespaces))
 
 	i := 0
@@ -105,39 +383,129 @@ func (server *Server) GetAllNamespaces() []*Namespace {
 	return namespaces
 }
 
-func (server *Server) addClient(client *Client) {
+// GetSession returns the open client with the session id, the client may have
+// left the root namespace while staying connected to other namespaces
+func (server *Server) GetSession(sessionId string) *Client {
+	server.sessionsMtx.RLock()
+	client, ok := server.sessions[sessionId]
+	server.sessionsMtx.RUnlock()
+	if !ok || !client.isOpen() {
+		return nil
+	}
+	return client
+}
+
+// reserves a room slot, fails if the room limit was reached
+func (server *Server) reserveRoom() bool {
+	for {
+		count := atomic.LoadInt32(&server.numOfRooms)
+		max := server.config().MaxNumOfRooms
+		if max > 0 && count >= max {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&server.numOfRooms, count, count + 1) {
+			return true
+		}
+	}
+}
+
+func (server *Server) releaseRoom() {
+	atomic.AddInt32(&server.numOfRooms, -1)
+}
+
+// reserves a client slot for a new session, fails if the client limit was reached,
+// the slot is released when the session ends or by the caller if it can't be opened
+func (server *Server) reserveClient(conf *ServerConf) bool {
+	for {
+		count := atomic.LoadInt32(&server.numOfClients)
+		if conf.MaxNumOfClients > 0 && count >= conf.MaxNumOfClients {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&server.numOfClients, count, count + 1) {
+			return true
+		}
+	}
+}
+
+func (server *Server) releaseClient() {
+	atomic.AddInt32(&server.numOfClients, -1)
+}
+
+func (server *Server) addClient(client *Client) bool {
 	server.mtx.Lock()
+	defer server.mtx.Unlock()
+	if !client.addNamespace(server.Namespace) {
+		return false
+	}
 	server.clients[client.uuid] = client
-	server.mtx.Unlock()
-	client.addNamespace(server.Namespace)
+	server.sessionsMtx.Lock()
+	server.sessions[client.uuid] = client
+	server.sessionsMtx.Unlock()
 	server.stats.Inc(stats.OpenedConnections)
+	return true
 }
 
-func (server *Server) addNamespaceClient(client *Client, namespaceName string) error {
-	server.mtx.Lock()
-	defer server.mtx.Unlock()
-	namespace, ok := server.namespaces[namespaceName]
-	if !ok {
-		return errors.New("Namespace doesn't exist")
+func (server *Server) addNamespaceClient(client *Client, packet *transport.Packet) error {
+	tracer := server.tracer()
+	_, span := tracer.Start(tracer.Extract(client.ctx, tracing.Carrier(packet.Trace)), tracing.ConnectSpan)
+	defer span.End()
+	span.SetAttribute(tracing.SessionKey, client.uuid)
+	span.SetAttribute(tracing.NamespaceKey, packet.Endpoint)
+
+	err := server.joinNamespace(client, packet)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+func (server *Server) joinNamespace(client *Client, packet *transport.Packet) error {
+	for {
+		namespace, err := server.getNamespace(packet.Endpoint, client)
+		if err != nil {
+			return err
+		}
+		client.handshake.setNamespaceQuery(packet.Endpoint, packet.Qs)
+		if authorize := namespace.conf.Authorize; authorize != nil {
+			if err = authorize(client, packet); err != nil {
+				server.discardDynamic(namespace)
+				if _, ok := err.(Error); !ok {
+					err = makeComplexError(Unauthorized, err)
+				}
+				return err
+			}
+		}
+		// retry if the namespace was removed before the client was added
+		if err = namespace.addClient(client); err != errNamespaceClosed {
+			if err == nil {
+				client.resend(namespace.name)
+			} else {
+				server.discardDynamic(namespace)
+			}
+			return err
+		}
 	}
-	namespace.addClient(client)
-	return nil
 }
 
+// removes the closed client from the root namespace and ends its session
 func (server *Server) removeClient(client *Client) {
 	server.mtx.Lock()
 	delete(server.Namespace.clients, client.uuid)
 	server.mtx.Unlock()
+	server.sessionsMtx.Lock()
+	delete(server.sessions, client.uuid)
+	server.sessionsMtx.Unlock()
+	server.releaseClient()
 	server.stats.Inc(stats.ClosedConnections)
 }
 
 func (server *Server) removeNamespaceClient(client *Client, namespaceName string) error {
-	server.mtx.Lock()
-	defer server.mtx.Unlock()
+	server.nsMtx.RLock()
 	namespace, ok := server.namespaces[namespaceName]
+	server.nsMtx.RUnlock()
 	if !ok {
 		return errors.New("Namespace doesn't exist")
 	}
-	namespace.removeClient(client)
+	namespace.disconnectClient(client)
 	return nil
 }
\ No newline at end of file
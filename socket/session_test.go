@@ -0,0 +1,178 @@
+package socket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// opens a long-polling session, returns the session id
+func openPollingSession(t *testing.T, server *Server, origin string) (string, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	w := httptest.NewRecorder()
+	server.ServePolling(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	var open pollingOpen
+	if err := json.Unmarshal([]byte(body[strings.Index(body, "{"):]), &open); err != nil {
+		t.Fatal(err)
+	}
+	return open.Sid, w
+}
+
+func TestSessionOutlivesRootNamespace(t *testing.T) {
+	conf := DefaultConf()
+	conf.MaxNumOfClients = 1
+	server := newTestServer(t, conf)
+	defer server.Stop()
+	if _, err := server.AddNamespace("/chat", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sid, _ := openPollingSession(t, server, "")
+	client := server.GetSession(sid)
+	if client == nil {
+		t.Fatal("Session wasn't registered")
+	}
+	connectTestClient(t, client, "/chat")
+	server.Namespace.disconnectClient(client)
+	if server.GetClient(sid) != nil {
+		t.Fatal("Client is still in the root namespace")
+	}
+
+	if server.pollingClient(sid) != client {
+		t.Fatal("Polling session was lost after leaving the root namespace")
+	}
+	if clients := server.ToSession(sid).In("/chat").GetClients(); len(clients) != 1 {
+		t.Fatalf("Expected the session to be targeted in /chat, got %d clients", len(clients))
+	}
+	w := httptest.NewRecorder()
+	server.ServePolling(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the client limit to count the session, got %d", w.Code)
+	}
+
+	client.Disconnect()
+	if server.GetSession(sid) != nil || atomic.LoadInt32(&server.numOfClients) != 0 {
+		t.Fatal("Session wasn't removed on disconnect")
+	}
+}
+
+func TestPollingAllowedOrigin(t *testing.T) {
+	conf := DefaultConf()
+	conf.AllowedOrigins = []string{"https://app.example.com"}
+	server := newTestServer(t, conf)
+	defer server.Stop()
+
+	_, w := openPollingSession(t, server, "https://app.example.com")
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+		t.Fatalf("Expected the allowed origin to be echoed, got %q", origin)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("Expected credentials to be allowed")
+	}
+
+	r := httptest.NewRequest("OPTIONS", "/?" + SessionParam + "=any", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Headers", "content-type")
+	w = httptest.NewRecorder()
+	server.ServePolling(w, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") != "content-type" {
+		t.Fatalf("Unexpected preflight response %d %v", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	server.ServePolling(w, r)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected the foreign origin to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSSEAllowedOrigin(t *testing.T) {
+	conf := DefaultConf()
+	conf.AllowedOrigins = []string{"app.example.com"}
+	server := newTestServer(t, conf)
+	defer server.Stop()
+
+	// the stream is rejected after the headers are set so the handler returns
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	server.SSEHandler(&SSEConf{Namespace: "/missing"}).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d", w.Code)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+		t.Fatalf("Expected the allowed origin to be echoed, got %q", origin)
+	}
+}
+
+func TestClientLimitUnderConcurrency(t *testing.T) {
+	conf := DefaultConf()
+	conf.MaxNumOfClients = 5
+	server := newTestServer(t, conf)
+	defer server.Stop()
+
+	var wg sync.WaitGroup
+	var opened int32
+	clients := make(chan *Client, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := server.ServeTransport(newTestTransport(), httptest.NewRequest("GET", "/", nil))
+			if err == nil {
+				atomic.AddInt32(&opened, 1)
+				clients <- client
+			}
+		}()
+	}
+	wg.Wait()
+	close(clients)
+	if opened != conf.MaxNumOfClients {
+		t.Fatalf("Expected %d admitted clients, got %d", conf.MaxNumOfClients, opened)
+	}
+
+	(<-clients).Disconnect()
+	if _, err := server.ServeTransport(newTestTransport(), httptest.NewRequest("GET", "/", nil)); err != nil {
+		t.Fatalf("Expected the released slot to be reused, got %v", err)
+	}
+	if _, err := server.ServeTransport(newTestTransport(), httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Fatal("Expected the client limit to be enforced")
+	}
+}
+
+func TestReloadKeepsProtocol(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	conf := DefaultConf()
+	conf.Protocol = SocketIOProtocol
+	if err := server.Reload(conf); err != nil {
+		t.Fatal(err)
+	}
+	if protocol := server.config().Protocol; protocol != GseProtocol {
+		t.Fatalf("Expected the protocol to stay %s, got %s", GseProtocol, protocol)
+	}
+}
+
+func TestReloadNil(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	if err := server.Reload(nil); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if server.config() == nil {
+		t.Fatal("Expected the configuration to be kept")
+	}
+}
@@ -0,0 +1,136 @@
+package socket
+
+import (
+	"net/http"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+	"github.com/ppincak/gse/utils"
+)
+
+const(
+	SSEBufferSize 	= 100
+	SSERetention 	= 30 * time.Second
+	SSEKeepAlive 	= 15 * time.Second
+)
+
+type SSEConf struct {
+	// namespace the streams are connected to, empty means the root namespace
+	Namespace 	string
+	// rooms of the namespace the streams join
+	Rooms 		[]string
+	// number of events kept per stream for Last-Event-ID replay
+	BufferSize 	int
+	// duration for which a dropped stream is kept for the browser to reconnect
+	Retention 	time.Duration
+	// interval of the comments keeping idle connections open
+	KeepAlive 	time.Duration
+}
+
+func DefaultSSEConf() *SSEConf {
+	return &SSEConf{
+		BufferSize: SSEBufferSize,
+		Retention: 	SSERetention,
+		KeepAlive: 	SSEKeepAlive,
+	}
+}
+
+// SSEHandler attaches Server-Sent Events streams as receive-only clients of the namespace
+// and rooms, events sent to them are delivered as "message" events with the gse packet as data.
+// A browser reconnecting with the Last-Event-ID header is attached to its previous stream
+// and receives the buffered events it missed. A nil conf uses the defaults.
+func (server *Server) SSEHandler(conf *SSEConf) http.Handler {
+	if conf == nil {
+		conf = DefaultSSEConf()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.serveSSE(w, r, conf)
+	})
+}
+
+func (server *Server) serveSSE(w http.ResponseWriter, r *http.Request, sseConf *SSEConf) {
+	server.confMtx.RLock()
+	conf, upgrader := server.conf, server.upgrader
+	server.confMtx.RUnlock()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkOrigin(upgrader, r) {
+		server.logger.WithFields(Fields{AddressField: r.RemoteAddr}).Warnf("Rejected event stream: origin not allowed")
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if !server.admitSecure(w, r, conf) {
+		return
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	for key, value := range conf.ResponseHeaders {
+		w.Header().Set(key, value)
+	}
+	allowOrigin(w, r, conf)
+
+	if sid, seq, ok := transport.ParseEventId(r.Header.Get("Last-Event-ID")); ok {
+		if stream := server.sseStream(sid); stream != nil {
+			stream.Serve(w, r, seq)
+			return
+		}
+	}
+	if !server.admitClient(w, r, conf) {
+		return
+	}
+
+	uuid := utils.GenerateUID()
+	stream := transport.NewSSE(uuid, transport.SSEConf{
+		BufferSize: sseConf.BufferSize,
+		Retention: 	sseConf.Retention,
+		KeepAlive: 	sseConf.KeepAlive,
+	})
+	client := server.openClient(r, conf, uuid, stream)
+	if err := server.joinStream(client, r, sseConf); err != nil {
+		client.logger.Warnf("Failed to attach event stream: %v", err)
+		client.Disconnect()
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	go client.readPump(stream)
+	stream.Serve(w, r, 0)
+}
+
+// connects the stream client to the namespace and rooms of the configuration
+func (server *Server) joinStream(client *Client, r *http.Request, sseConf *SSEConf) error {
+	namespace := server.Namespace
+	if sseConf.Namespace != "" && sseConf.Namespace != server.Namespace.name {
+		err := server.addNamespaceClient(client, &transport.Packet{
+			PacketType: transport.Connect,
+			Endpoint: 	sseConf.Namespace,
+			Qs: 		r.URL.RawQuery,
+		})
+		if err != nil {
+			return err
+		}
+		if namespace = client.getNamespace(sseConf.Namespace); namespace == nil {
+			return makeError(NamespaceDoesNotExist)
+		}
+	}
+	socketClient := client.wrap(namespace)
+	for _, roomName := range sseConf.Rooms {
+		if err := socketClient.JoinRoom(roomName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the transport of an open stream client
+func (server *Server) sseStream(sid string) *transport.SSE {
+	client := server.GetSession(sid)
+	if client == nil {
+		return nil
+	}
+	stream, _ := client.getTransport().(*transport.SSE)
+	return stream
+}
@@ -0,0 +1,187 @@
+package socket
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// response writer which can be read while the stream writes to it
+type streamRecorder struct {
+	header 	http.Header
+	body 	bytes.Buffer
+	code 	int
+	mtx 	sync.Mutex
+}
+
+func newStreamRecorder() *streamRecorder {
+	return &streamRecorder{header: make(http.Header)}
+}
+
+func (w *streamRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *streamRecorder) Write(data []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.body.Write(data)
+}
+
+func (w *streamRecorder) WriteHeader(code int) {
+	w.mtx.Lock()
+	w.code = code
+	w.mtx.Unlock()
+}
+
+func (w *streamRecorder) Flush() {}
+
+// returns the ids of the received events
+func (w *streamRecorder) eventIds() []string {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	ids := make([]string, 0)
+	for _, line := range strings.Split(w.body.String(), "\n") {
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		}
+	}
+	return ids
+}
+
+// serves the request in the background, the returned function ends it and waits for the handler
+func serveStream(handler http.Handler, lastEventId string) (*streamRecorder, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	if lastEventId != "" {
+		r.Header.Set("Last-Event-ID", lastEventId)
+	}
+	w := newStreamRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, r)
+		close(done)
+	}()
+	return w, func() {
+		cancel()
+		<-done
+	}
+}
+
+func awaitStreamClient(t *testing.T, server *Server) {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(server.GetClients()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Stream client wasn't opened")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func awaitEventIds(t *testing.T, w *streamRecorder, n int) []string {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if ids := w.eventIds(); len(ids) >= n {
+			return ids
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d events, got %v", n, w.eventIds())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSSEReplayBuffer(t *testing.T) {
+	stream := transport.NewSSE("s", transport.SSEConf{BufferSize: 2, Retention: time.Minute})
+	defer stream.Close()
+	for i := 0; i < 3; i++ {
+		stream.Write([]transport.Frame{{Data: []byte("event")}})
+	}
+
+	replay := func(lastSeq uint64) []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := newStreamRecorder()
+		done := make(chan struct{})
+		go func() {
+			stream.Serve(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx), lastSeq)
+			close(done)
+		}()
+		cancel()
+		<-done
+		return w.eventIds()
+	}
+
+	// the oldest event was dropped from the buffer
+	if ids := replay(0); strings.Join(ids, ",") != "s-2,s-3" {
+		t.Fatalf("Expected the buffered events, got %v", ids)
+	}
+	if ids := replay(2); strings.Join(ids, ",") != "s-3" {
+		t.Fatalf("Expected the events after 2, got %v", ids)
+	}
+	if ids := replay(3); len(ids) != 0 {
+		t.Fatalf("Expected no events, got %v", ids)
+	}
+}
+
+func TestParseEventId(t *testing.T) {
+	if stream, seq, ok := transport.ParseEventId("a-b-12"); !ok || stream != "a-b" || seq != 12 {
+		t.Fatalf("Unexpected id %q %d %v", stream, seq, ok)
+	}
+	for _, id := range []string{"", "12", "-12", "a-", "a-x"} {
+		if _, _, ok := transport.ParseEventId(id); ok {
+			t.Fatalf("Expected %q to be invalid", id)
+		}
+	}
+}
+
+func TestSSELastEventIdReplay(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	conf := DefaultSSEConf()
+	conf.KeepAlive = 0
+	handler := server.SSEHandler(conf)
+
+	w, stop := serveStream(handler, "")
+	awaitStreamClient(t, server)
+	server.Emit("first")
+	ids := awaitEventIds(t, w, 1)
+	stop()
+
+	// events sent while the browser reconnects are buffered
+	server.Emit("second")
+	server.Emit("third")
+
+	w, stop = serveStream(handler, ids[0])
+	defer stop()
+	replayed := awaitEventIds(t, w, 2)
+	sid, seq, _ := transport.ParseEventId(ids[0])
+	expected := []string{sid + "-2", sid + "-3"}
+	if seq != 1 || strings.Join(replayed, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the missed events %v after %s, got %v", expected, ids[0], replayed)
+	}
+	if server.GetSession(sid) == nil || len(server.GetClients()) != 1 {
+		t.Fatal("Expected the stream to be reattached to the same client")
+	}
+}
+
+func TestSSEUnknownLastEventIdOpensNewStream(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	conf := DefaultSSEConf()
+	conf.KeepAlive = 0
+
+	w, stop := serveStream(server.SSEHandler(conf), "missing-5")
+	defer stop()
+	awaitStreamClient(t, server)
+	server.Emit("event")
+	ids := awaitEventIds(t, w, 1)
+	if sid, seq, ok := transport.ParseEventId(ids[0]); !ok || sid == "missing" || seq != 1 {
+		t.Fatalf("Expected a new stream, got %v", ids)
+	}
+}
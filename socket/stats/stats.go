@@ -1,5 +1,9 @@
 package stats
 
+import (
+	"sync/atomic"
+)
+
 const(
 	OpenedConnections = iota
 	ClosedConnections
@@ -7,8 +11,11 @@ const(
 	ClosedRooms
 	ConnectionFailures
 	PacketFailures
+	ThrottledPackets
 )
 
+// Stats holds the server counters, they are updated atomically so all the methods
+// are safe for concurrent use, the fields must be read through Clone
 type Stats struct {
 	OpenedConnections  uint64		`json:"openedConnections"`
 	ClosedConnections  uint64		`json:"closedConnections"`
@@ -16,70 +23,63 @@ type Stats struct {
 	ClosedRooms        uint64		`json:"closedRooms"`
 	ConnectionFailures uint64		`json:"connectionFailures"`
 	PacketFailures     uint64		`json:"PacketFailures"`
-	incrc              chan int
-	statc              chan chan<- Stats
-	stopc              chan struct{}
+	ThrottledPackets   uint64		`json:"throttledPackets"`
 }
 
-const (
-	StatsBufferSize = 100
-)
-
 func NewStats() *Stats {
-	return &Stats{
-		incrc: make(chan int, StatsBufferSize),
-		statc: make(chan chan<- Stats),
-		stopc: make(chan struct{}),
-	}
+	return &Stats{}
 }
 
-func (stats *Stats) Run() {
-	go func(stats *Stats) {
-		for {
-			select {
-				case field := <- stats.incrc:
-					switch field {
-						case OpenedConnections:
-							stats.OpenedConnections++
-						case ClosedConnections:
-							stats.ClosedConnections++
-						case OpenedRooms:
-							stats.OpenedRooms++
-						case ClosedRooms:
-							stats.ClosedRooms++
-						case ConnectionFailures:
-							stats.ConnectionFailures++
-						case PacketFailures:
-							stats.PacketFailures++
-					}
-				case c := <- stats.statc:
-					c <- stats.Clone()
-				case <- stats.stopc:
-					return
-			}
-		}
-	}(stats)
-}
+// Deprecated: the counters no longer need a routine, Run has no effect
+func (stats *Stats) Run() {}
 
-func (stats *Stats) Stop() {
-	stats.stopc <- struct{}{}
-}
+// Deprecated: the counters no longer need a routine, Stop has no effect
+func (stats *Stats) Stop() {}
 
+// Get sends a copy of the counters to the channel without blocking the caller
+//
+// Deprecated: use Clone which returns the copy directly
 func (stats *Stats) Get(c chan<- Stats) {
-	stats.statc <- c
+	clone := stats.Clone()
+	go func() {
+		c <- clone
+	}()
 }
 
 func (stats *Stats) Inc(field int) {
-	stats.incrc <- field
+	if counter := stats.counter(field); counter != nil {
+		atomic.AddUint64(counter, 1)
+	}
+}
+
+func (stats *Stats) counter(field int) *uint64 {
+	switch field {
+		case OpenedConnections:
+			return &stats.OpenedConnections
+		case ClosedConnections:
+			return &stats.ClosedConnections
+		case OpenedRooms:
+			return &stats.OpenedRooms
+		case ClosedRooms:
+			return &stats.ClosedRooms
+		case ConnectionFailures:
+			return &stats.ConnectionFailures
+		case PacketFailures:
+			return &stats.PacketFailures
+		case ThrottledPackets:
+			return &stats.ThrottledPackets
+	}
+	return nil
 }
 
 func (stats *Stats) Clone() Stats {
 	return Stats {
-		OpenedConnections:	stats.OpenedConnections,
-		ClosedConnections: 	stats.ClosedConnections,
-		OpenedRooms: 		stats.OpenedRooms,
-		ClosedRooms: 		stats.ClosedRooms,
-		ConnectionFailures: stats.ConnectionFailures,
-		PacketFailures:		stats.PacketFailures,
+		OpenedConnections:	atomic.LoadUint64(&stats.OpenedConnections),
+		ClosedConnections: 	atomic.LoadUint64(&stats.ClosedConnections),
+		OpenedRooms: 		atomic.LoadUint64(&stats.OpenedRooms),
+		ClosedRooms: 		atomic.LoadUint64(&stats.ClosedRooms),
+		ConnectionFailures: atomic.LoadUint64(&stats.ConnectionFailures),
+		PacketFailures:		atomic.LoadUint64(&stats.PacketFailures),
+		ThrottledPackets:	atomic.LoadUint64(&stats.ThrottledPackets),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGetConcurrentWithInc(t *testing.T) {
+	stats := NewStats()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.Inc(OpenedConnections)
+				stats.Inc(ThrottledPackets)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.Clone()
+			}
+		}()
+	}
+	wg.Wait()
+
+	counters := stats.Clone()
+	if counters.OpenedConnections != 1000 || counters.ThrottledPackets != 1000 {
+		t.Fatalf("Expected 1000 connections and throttled packets, got %+v", counters)
+	}
+	stats.Inc(-1)
+	if stats.Clone() != counters {
+		t.Fatal("Unknown counters must be ignored")
+	}
+}
+
+func TestGet(t *testing.T) {
+	stats := NewStats()
+	stats.Inc(OpenedRooms)
+	c := make(chan Stats)
+	stats.Get(c)
+	if counters := <-c; counters.OpenedRooms != 1 {
+		t.Fatalf("Expected 1 opened room, got %+v", counters)
+	}
+}
@@ -0,0 +1,52 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"github.com/ppincak/gse/socket/tracing"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+var errInsecureConnection = errors.New("Insecure connection")
+
+func (server *Server) tracer() tracing.Tracer {
+	if tracer := server.config().Tracer; tracer != nil {
+		return tracer
+	}
+	return tracing.Noop()
+}
+
+// sets the trace context of the span in the context on the packet
+func injectTrace(tracer tracing.Tracer, ctx context.Context, packet *transport.Packet) {
+	carrier := tracing.Carrier{}
+	tracer.Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		packet.Trace = carrier
+	}
+}
+
+// returns the first values of the headers with lower case names, e.g. traceparent
+func headerCarrier(header http.Header) tracing.Carrier {
+	carrier := make(tracing.Carrier, len(header))
+	for key, values := range header {
+		if len(values) > 0 {
+			carrier[strings.ToLower(key)] = values[0]
+		}
+	}
+	return carrier
+}
+
+// sends the packet to the clients inside an emit span, the trace context is propagated with the packet
+func (namespace *Namespace) emit(ctx context.Context, clients []*Client, packet *transport.Packet) {
+	tracer := namespace.server.tracer()
+	ctx, span := tracer.Start(ctx, tracing.EmitSpan)
+	defer span.End()
+	span.SetAttribute(tracing.NamespaceKey, namespace.name)
+	span.SetAttribute(tracing.EventKey, packet.Name)
+	span.SetAttribute(tracing.RecipientsKey, len(clients))
+
+	injectTrace(tracer, ctx, packet)
+	namespace.broadcast(clients, packet)
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context header used for propagation
+const TraceParentKey = "traceparent"
+
+type spanContextKey struct{}
+
+type spanContext struct {
+	traceId 	string
+	spanId 		string
+}
+
+// RecordedSpan is a snapshot of a span kept by the Recorder
+type RecordedSpan struct {
+	Name 		string
+	TraceId 	string
+	SpanId 		string
+	// id of the parent span, empty for root spans
+	ParentId 	string
+	Attributes 	map[string]interface{}
+	Errors 		[]error
+	Start 		time.Time
+	// zero while the span is running
+	End 		time.Time
+}
+
+// Recorder is an in-memory tracer for tests, it propagates the context in the traceparent format
+type Recorder struct {
+	spans 	[]*recorderSpan
+	mtx 	*sync.Mutex
+}
+
+type recorderSpan struct {
+	recorder 	*Recorder
+	span 		RecordedSpan
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		spans: 	make([]*recorderSpan, 0),
+		mtx: 	new(sync.Mutex),
+	}
+}
+
+func (recorder *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recorderSpan{
+		recorder: recorder,
+		span: RecordedSpan{
+			Name: 		name,
+			SpanId: 	randomId(8),
+			Attributes: make(map[string]interface{}),
+			Start: 		time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		span.span.TraceId, span.span.ParentId = parent.traceId, parent.spanId
+	} else {
+		span.span.TraceId = randomId(16)
+	}
+
+	recorder.mtx.Lock()
+	recorder.spans = append(recorder.spans, span)
+	recorder.mtx.Unlock()
+	return context.WithValue(ctx, spanContextKey{}, spanContext{span.span.TraceId, span.span.SpanId}), span
+}
+
+func (recorder *Recorder) Extract(ctx context.Context, carrier Carrier) context.Context {
+	parts := strings.Split(carrier[TraceParentKey], "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, spanContext{parts[1], parts[2]})
+}
+
+func (recorder *Recorder) Inject(ctx context.Context, carrier Carrier) {
+	if sc, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		carrier[TraceParentKey] = "00-" + sc.traceId + "-" + sc.spanId + "-01"
+	}
+}
+
+// Spans returns snapshots of the recorded spans in the order they were started
+func (recorder *Recorder) Spans() []RecordedSpan {
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	spans := make([]RecordedSpan, len(recorder.spans))
+	for i, span := range recorder.spans {
+		spans[i] = span.span
+		spans[i].Attributes = make(map[string]interface{}, len(span.span.Attributes))
+		for key, value := range span.span.Attributes {
+			spans[i].Attributes[key] = value
+		}
+		spans[i].Errors = append([]error(nil), span.span.Errors...)
+	}
+	return spans
+}
+
+// Find returns the recorded spans with the name
+func (recorder *Recorder) Find(name string) []RecordedSpan {
+	spans := make([]RecordedSpan, 0)
+	for _, span := range recorder.Spans() {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func (recorder *Recorder) Reset() {
+	recorder.mtx.Lock()
+	recorder.spans = make([]*recorderSpan, 0)
+	recorder.mtx.Unlock()
+}
+
+func (span *recorderSpan) SetAttribute(key string, value interface{}) {
+	span.recorder.mtx.Lock()
+	span.span.Attributes[key] = value
+	span.recorder.mtx.Unlock()
+}
+
+func (span *recorderSpan) RecordError(err error) {
+	span.recorder.mtx.Lock()
+	span.span.Errors = append(span.span.Errors, err)
+	span.recorder.mtx.Unlock()
+}
+
+func (span *recorderSpan) End() {
+	span.recorder.mtx.Lock()
+	if span.span.End.IsZero() {
+		span.span.End = time.Now()
+	}
+	span.recorder.mtx.Unlock()
+}
+
+func randomId(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,70 @@
+// Package tracing defines the hooks the server calls around connections and events,
+// the interfaces follow the OpenTelemetry model so an adapter can be a thin wrapper.
+package tracing
+
+import (
+	"context"
+)
+
+// span names
+const(
+	UpgradeSpan 	= "gse.upgrade"
+	ConnectSpan 	= "gse.connect"
+	EventSpan 		= "gse.event"
+	EmitSpan 		= "gse.emit"
+	AckSpan 		= "gse.ack"
+)
+
+// attribute keys
+const(
+	SessionKey 		= "gse.session"
+	NamespaceKey 	= "gse.namespace"
+	EventKey 		= "gse.event"
+	TransportKey 	= "gse.transport"
+	AddressKey 		= "gse.address"
+	AckIdKey 		= "gse.ack_id"
+	RecipientsKey 	= "gse.recipients"
+)
+
+// Carrier holds a propagated trace context, e.g. the traceparent header
+type Carrier map[string]string
+
+type Tracer interface {
+	// Start begins a span as a child of the span in the context
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Extract returns the context with the remote parent read from the carrier
+	Extract(ctx context.Context, carrier Carrier) context.Context
+	// Inject writes the trace context of the span in the context to the carrier
+	Inject(ctx context.Context, carrier Carrier)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+// Noop returns a tracer which records nothing
+func Noop() Tracer {
+	return noopTracer{}
+}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTracer) Extract(ctx context.Context, carrier Carrier) context.Context {
+	return ctx
+}
+
+func (noopTracer) Inject(ctx context.Context, carrier Carrier) {}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
@@ -0,0 +1,45 @@
+package transport
+
+// Frame is a single message of the underlying connection
+type Frame struct {
+	Data 	[]byte
+	Binary 	bool
+}
+
+// Codec converts packets to the wire format of a protocol,
+// Decode is called only from the connection read loop while Encode can be called concurrently
+type Codec interface {
+	// name of the protocol, codecs with the same name encode events to the same frames
+	Name() string
+	// returns the frames of the packet in the order they must be written
+	Encode(*Packet) ([]Frame, error)
+	// returns the packets completed by the frame, control frames may yield no packets
+	Decode(Frame) ([]*Packet, error)
+}
+
+const(
+	JsonProtocol = "gse"
+)
+
+// JsonCodec is the native gse protocol, every packet is a single json text frame
+type JsonCodec struct{}
+
+func (JsonCodec) Name() string {
+	return JsonProtocol
+}
+
+func (JsonCodec) Encode(packet *Packet) ([]Frame, error) {
+	bytes, err := Encode(packet)
+	if err != nil {
+		return nil, err
+	}
+	return []Frame{{Data: bytes}}, nil
+}
+
+func (JsonCodec) Decode(frame Frame) ([]*Packet, error) {
+	packet, err := Decode(frame.Data)
+	if err != nil {
+		return nil, err
+	}
+	return []*Packet{packet}, nil
+}
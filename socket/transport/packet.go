@@ -10,6 +10,10 @@ const(
 	Event
 	Ack
 	Error
+	ConnectError
+	Ping
+	Pong
+	History
 )
 
 var PacketTypeMap = map[string] PacketType {
@@ -18,6 +22,10 @@ var PacketTypeMap = map[string] PacketType {
 	"event": 		Event,
 	"ack": 			Ack,
 	"error": 		Error,
+	"connectError": ConnectError,
+	"ping": 		Ping,
+	"pong": 		Pong,
+	"history": 		History,
 }
 
 type Packet struct {
@@ -33,8 +41,12 @@ type Packet struct {
 	Id       	int64			`json:"id,omitempty"`
 	// event name
 	Name     	string			`json:"name"`
-	// event arguments
-	Args     	interface{}		`json:"args,omitempty"`
+	// positional event arguments
+	Args     	[]interface{}	`json:"args,omitempty"`
+	// reserved for the propagated trace context
+	Trace 		map[string]string 	`json:"trace,omitempty"`
+	// history id of an event recorded by a room, used to request the messages sent after it
+	Seq 		int64 			`json:"seq,omitempty"`
 }
 
 func Encode(packet *Packet) ([]byte, error) {
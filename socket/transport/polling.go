@@ -0,0 +1,269 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// frames of a polling payload are separated by the record separator,
+// binary frames are sent base64 encoded with the "b" prefix
+const payloadSeparator = '\x1e'
+
+var(
+	ErrPollingTimeout 	= errors.New("Client stopped polling")
+	ErrOverlappingPoll 	= errors.New("Overlapping poll request")
+	ErrPayloadTooLarge 	= errors.New("Payload too large")
+)
+
+type PollingConf struct {
+	// duration for which a poll request is held open waiting for frames,
+	// a client which doesn't poll again within this duration is disconnected
+	PollTimeout 	time.Duration
+	// maximum size of a posted payload in bytes, zero means unlimited
+	MaxPayload 		int64
+	// frame answered to a poll which timed out, nil answers with an empty payload
+	Keepalive 		*Frame
+}
+
+// Polling is an HTTP long-polling transport, frames are received through
+// POST requests and sent in batches as responses to GET requests
+type Polling struct {
+	conf 		PollingConf
+	// frames waiting for the next poll
+	queue 		[]Frame
+	// signals the pending poll that frames were queued
+	ready 		chan struct{}
+	// frames received through POST requests
+	inbound 	chan Frame
+	// closed when the transport is closed
+	closec 		chan struct{}
+	// reason of closing
+	err 		error
+	// flag indicating that a poll request is pending
+	polling 	bool
+	// disconnects clients which stopped polling
+	idle 		*time.Timer
+	// lock
+	mtx 		*sync.Mutex
+}
+
+func NewPolling(conf PollingConf) *Polling {
+	polling := &Polling{
+		conf: 		conf,
+		queue: 		make([]Frame, 0),
+		ready: 		make(chan struct{}, 1),
+		inbound: 	make(chan Frame),
+		closec: 	make(chan struct{}),
+		mtx: 		new(sync.Mutex),
+	}
+	polling.idle = time.AfterFunc(conf.PollTimeout, func() {
+		polling.close(ErrPollingTimeout)
+	})
+	return polling
+}
+
+func (polling *Polling) Name() string {
+	return PollingTransport
+}
+
+func (polling *Polling) Read() (Frame, error) {
+	select {
+		case frame := <-polling.inbound:
+			return frame, nil
+		case <-polling.closec:
+			return Frame{}, polling.closeErr()
+	}
+}
+
+// Write queues the frames for the next poll, it never blocks
+func (polling *Polling) Write(frames []Frame) error {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	if polling.err != nil {
+		return polling.err
+	}
+	polling.queue = append(polling.queue, frames...)
+	select {
+		case polling.ready <- struct{}{}:
+		default:
+	}
+	return nil
+}
+
+func (polling *Polling) Close() error {
+	polling.close(ErrTransportClosed)
+	return nil
+}
+
+// Drain returns the frames which were not polled yet, used when the client is
+// upgraded to another transport after the polling transport was closed
+func (polling *Polling) Drain() []Frame {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	frames := polling.queue
+	polling.queue = make([]Frame, 0)
+	return frames
+}
+
+func (polling *Polling) close(err error) {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	if polling.err != nil {
+		return
+	}
+	polling.err = err
+	polling.idle.Stop()
+	close(polling.closec)
+}
+
+func (polling *Polling) closeErr() error {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	return polling.err
+}
+
+// marks the start or the end of a poll request, the idle timer runs only between polls
+func (polling *Polling) setPolling(active bool) error {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	if polling.err != nil {
+		return polling.err
+	}
+	if active && polling.polling {
+		return ErrOverlappingPoll
+	}
+	polling.polling = active
+	if active {
+		polling.idle.Stop()
+	} else {
+		polling.idle.Reset(polling.conf.PollTimeout)
+	}
+	return nil
+}
+
+// takes the queued frames unless the transport was closed
+func (polling *Polling) take() []Frame {
+	polling.mtx.Lock()
+	defer polling.mtx.Unlock()
+	if polling.err != nil || len(polling.queue) == 0 {
+		return nil
+	}
+	frames := polling.queue
+	polling.queue = make([]Frame, 0)
+	return frames
+}
+
+// ServePoll answers a GET request with the queued frames, waiting for frames
+// up to the poll timeout
+func (polling *Polling) ServePoll(w http.ResponseWriter, r *http.Request) {
+	if err := polling.setPolling(true); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer polling.setPolling(false)
+
+	timer := time.NewTimer(polling.conf.PollTimeout)
+	defer timer.Stop()
+
+	for {
+		if frames := polling.take(); frames != nil {
+			WritePayload(w, frames)
+			return
+		}
+		select {
+			case <-polling.ready:
+			case <-timer.C:
+				polling.writeKeepalive(w)
+				return
+			case <-polling.closec:
+				polling.writeKeepalive(w)
+				return
+			case <-r.Context().Done():
+				return
+		}
+	}
+}
+
+func (polling *Polling) writeKeepalive(w http.ResponseWriter) {
+	if polling.conf.Keepalive != nil {
+		WritePayload(w, []Frame{*polling.conf.Keepalive})
+	} else {
+		WritePayload(w, nil)
+	}
+}
+
+// ServeSend reads the frames of a POST request, the request completes
+// once all the frames were read by the client
+func (polling *Polling) ServeSend(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if polling.conf.MaxPayload > 0 {
+		body = io.LimitReader(r.Body, polling.conf.MaxPayload + 1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if polling.conf.MaxPayload > 0 && int64(len(data)) > polling.conf.MaxPayload {
+		http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	frames, err := DecodePayload(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, frame := range frames {
+		select {
+			case polling.inbound <- frame:
+			case <-polling.closec:
+				http.Error(w, polling.closeErr().Error(), http.StatusBadRequest)
+				return
+		}
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte("ok"))
+}
+
+// WritePayload writes the frames as a polling response
+func WritePayload(w http.ResponseWriter, frames []Frame) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.Write(EncodePayload(frames))
+}
+
+func EncodePayload(frames []Frame) []byte {
+	var buffer bytes.Buffer
+	for i, frame := range frames {
+		if i > 0 {
+			buffer.WriteByte(payloadSeparator)
+		}
+		buffer.Write(frameData(frame))
+	}
+	return buffer.Bytes()
+}
+
+func DecodePayload(data []byte) ([]Frame, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	parts := bytes.Split(data, []byte{payloadSeparator})
+	frames := make([]Frame, len(parts))
+	for i, part := range parts {
+		if len(part) > 0 && part[0] == 'b' {
+			decoded, err := base64.StdEncoding.DecodeString(string(part[1:]))
+			if err != nil {
+				return nil, err
+			}
+			frames[i] = Frame{Data: decoded, Binary: true}
+		} else {
+			frames[i] = Frame{Data: part}
+		}
+	}
+	return frames, nil
+}
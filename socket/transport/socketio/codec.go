@@ -0,0 +1,510 @@
+// Package socketio implements the Engine.IO v4 / Socket.IO v5 wire protocol
+// on top of the gse packets, so the standard socket.io-client can be used.
+package socketio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+const(
+	Protocol = "socketio"
+	// engine.io protocol revision
+	EngineVersion = "4"
+)
+
+// engine.io packet types
+const(
+	eioOpen 	= '0'
+	eioClose 	= '1'
+	eioPing 	= '2'
+	eioPong 	= '3'
+	eioMessage 	= '4'
+	eioUpgrade 	= '5'
+	eioNoop 	= '6'
+)
+
+// socket.io packet types
+const(
+	sioConnect 		= '0'
+	sioDisconnect 	= '1'
+	sioEvent 		= '2'
+	sioAck 			= '3'
+	sioConnectError = '4'
+	sioBinaryEvent 	= '5'
+	sioBinaryAck 	= '6'
+)
+
+const rootNamespace = "/"
+
+// payload of the upgrade probe ping and pong
+const probe = "probe"
+
+// reserved event of room history requests and of history sent without a request
+const historyEvent = "history"
+
+type openPacket struct {
+	Sid          	string 		`json:"sid"`
+	Upgrades     	[]string 	`json:"upgrades"`
+	PingInterval 	int64  		`json:"pingInterval"`
+	PingTimeout  	int64  		`json:"pingTimeout"`
+	MaxPayload   	int64  		`json:"maxPayload"`
+}
+
+// OpenFrame is the engine.io handshake sent by the server when the connection is opened
+func OpenFrame(sid string, upgrades []string, pingInterval, pingTimeout time.Duration, maxPayload int64) transport.Frame {
+	if upgrades == nil {
+		upgrades = []string{}
+	}
+	raw, _ := json.Marshal(openPacket{
+		Sid: 			sid,
+		Upgrades: 		upgrades,
+		PingInterval: 	int64(pingInterval / time.Millisecond),
+		PingTimeout: 	int64(pingTimeout / time.Millisecond),
+		MaxPayload: 	maxPayload,
+	})
+	return transport.Frame{Data: append([]byte{eioOpen}, raw...)}
+}
+
+// NoopFrame is used to release a pending polling request during the transport upgrade
+func NoopFrame() transport.Frame {
+	return transport.Frame{Data: []byte{eioNoop}}
+}
+
+// Codec maps socket.io packets to gse packets:
+// connect, disconnect and connect_error keep their meaning, events become Event packets
+// or Ack packets when the client expects an acknowledgement, acks sent by the client become
+// Ack packets without a name, engine.io ping and pong become Ping and Pong packets.
+// Ack ids are shifted by one since socket.io starts them at zero.
+// The "history" event with the room and an optional query is a History request, its
+// messages are sent as the ack of the request or as the "history" event with the room
+// and the messages. Events sent to a room with history enabled carry the seq of their
+// history message, it is appended to their arguments as a string, the offset socket.io
+// clients with connection state recovery strip and keep. Other events have no trailing seq.
+// Decode must be called from a single goroutine, Encode is safe for concurrent use.
+type Codec struct {
+	// session id sent in namespace connect responses
+	sid 			string
+	// binary packet waiting for its attachments
+	pending 		*transport.Packet
+	// payload of the pending packet containing placeholders
+	pendingArgs 	[]interface{}
+	// received attachments of the pending packet
+	attachments 	[][]byte
+	// number of attachments of the pending packet
+	expected 		int
+}
+
+func NewCodec(sid string) *Codec {
+	return &Codec{
+		sid: sid,
+	}
+}
+
+func (codec *Codec) Name() string {
+	return Protocol
+}
+
+func (codec *Codec) Decode(frame transport.Frame) ([]*transport.Packet, error) {
+	if frame.Binary {
+		return codec.decodeAttachment(frame.Data)
+	}
+	if len(frame.Data) == 0 {
+		return nil, errors.New("socketio: empty frame")
+	}
+
+	data := frame.Data[1:]
+	switch frame.Data[0] {
+		case eioPing:
+			return []*transport.Packet{{PacketType: transport.Ping, Data: string(data)}}, nil
+		case eioPong:
+			return []*transport.Packet{{PacketType: transport.Pong, Data: string(data)}}, nil
+		case eioMessage:
+			return codec.decodeMessage(data)
+		case eioClose, eioUpgrade, eioNoop:
+			return nil, nil
+	}
+	return nil, fmt.Errorf("socketio: unknown engine.io packet type %q", frame.Data[0])
+}
+
+func (codec *Codec) decodeAttachment(data []byte) ([]*transport.Packet, error) {
+	if codec.pending == nil {
+		return nil, errors.New("socketio: unexpected binary attachment")
+	}
+	codec.attachments = append(codec.attachments, append([]byte(nil), data...))
+	if len(codec.attachments) < codec.expected {
+		return nil, nil
+	}
+
+	packet := codec.pending
+	args, err := replacePlaceholders(codec.pendingArgs, codec.attachments)
+	codec.pending, codec.pendingArgs, codec.attachments, codec.expected = nil, nil, nil, 0
+	if err != nil {
+		return nil, err
+	}
+	setArgs(packet, args.([]interface{}))
+	return []*transport.Packet{packet}, nil
+}
+
+func (codec *Codec) decodeMessage(data []byte) ([]*transport.Packet, error) {
+	if len(data) == 0 {
+		return nil, errors.New("socketio: empty message")
+	}
+	if codec.pending != nil {
+		return nil, errors.New("socketio: packet received while waiting for binary attachments")
+	}
+
+	sioType := data[0]
+	rest := data[1:]
+
+	attachments := 0
+	if sioType == sioBinaryEvent || sioType == sioBinaryAck {
+		i := bytes.IndexByte(rest, '-')
+		if i < 0 {
+			return nil, errors.New("socketio: missing attachment count")
+		}
+		count, err := strconv.Atoi(string(rest[:i]))
+		if err != nil {
+			return nil, errors.New("socketio: invalid attachment count")
+		}
+		attachments = count
+		rest = rest[i+1:]
+	}
+
+	namespace := rootNamespace
+	if len(rest) > 0 && rest[0] == '/' {
+		i := bytes.IndexByte(rest, ',')
+		if i < 0 {
+			namespace, rest = string(rest), nil
+		} else {
+			namespace, rest = string(rest[:i]), rest[i+1:]
+		}
+	}
+	// socket.io v2 clients send the query of a namespace connection after the namespace
+	query := ""
+	if i := strings.IndexByte(namespace, '?'); i >= 0 {
+		namespace, query = namespace[:i], namespace[i+1:]
+	}
+
+	var id int64
+	hasId := false
+	i := 0
+	for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i > 0 {
+		wire, _ := strconv.ParseInt(string(rest[:i]), 10, 64)
+		id = packetId(wire)
+		hasId = true
+		rest = rest[i:]
+	}
+
+	var payload interface{}
+	if len(rest) > 0 {
+		if err := json.Unmarshal(rest, &payload); err != nil {
+			return nil, fmt.Errorf("socketio: invalid payload: %v", err)
+		}
+	}
+
+	packet := &transport.Packet{
+		Endpoint: 	namespace,
+		Id: 		id,
+	}
+	switch sioType {
+		case sioConnect:
+			packet.PacketType = transport.Connect
+			packet.Data = payload
+			packet.Qs = authQuery(payload)
+			if packet.Qs == "" {
+				packet.Qs = query
+			}
+			return []*transport.Packet{packet}, nil
+		case sioDisconnect:
+			packet.PacketType = transport.Disconnect
+			return []*transport.Packet{packet}, nil
+		case sioEvent, sioBinaryEvent:
+			items, ok := payload.([]interface{})
+			if !ok || len(items) == 0 {
+				return nil, errors.New("socketio: event payload must be a non empty array")
+			}
+			name, ok := items[0].(string)
+			if !ok {
+				return nil, errors.New("socketio: event name must be a string")
+			}
+			if name == historyEvent && sioType == sioEvent {
+				return historyRequest(packet, items[1:])
+			}
+			packet.Name = name
+			packet.PacketType = transport.Event
+			if hasId {
+				packet.PacketType = transport.Ack
+			}
+			return codec.withAttachments(packet, items[1:], attachments)
+		case sioAck, sioBinaryAck:
+			items, ok := payload.([]interface{})
+			if !ok || !hasId {
+				return nil, errors.New("socketio: ack must have an id and an array payload")
+			}
+			packet.PacketType = transport.Ack
+			return codec.withAttachments(packet, items, attachments)
+	}
+	return nil, fmt.Errorf("socketio: unsupported packet type %q", sioType)
+}
+
+// the room of the request is the first argument and the optional query the second
+func historyRequest(packet *transport.Packet, args []interface{}) ([]*transport.Packet, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("socketio: history request must have a room and an optional query")
+	}
+	room, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("socketio: history room must be a string")
+	}
+	packet.PacketType = transport.History
+	packet.Name = room
+	if len(args) == 2 {
+		packet.Data = args[1]
+	}
+	return []*transport.Packet{packet}, nil
+}
+
+func (codec *Codec) withAttachments(packet *transport.Packet, args []interface{}, attachments int) ([]*transport.Packet, error) {
+	if attachments == 0 {
+		setArgs(packet, args)
+		return []*transport.Packet{packet}, nil
+	}
+	codec.pending = packet
+	codec.pendingArgs = args
+	codec.expected = attachments
+	return nil, nil
+}
+
+// data only listeners receive the single argument or all of them
+func setArgs(packet *transport.Packet, args []interface{}) {
+	packet.Args = args
+	if len(args) == 1 {
+		packet.Data = args[0]
+	} else if len(args) > 1 {
+		packet.Data = args
+	}
+}
+
+// the auth object of a connect packet exposed as a query string
+func authQuery(payload interface{}) string {
+	auth, ok := payload.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	query := url.Values{}
+	for key, value := range auth {
+		switch v := value.(type) {
+			case string:
+				query.Set(key, v)
+			case float64, bool:
+				query.Set(key, fmt.Sprint(v))
+		}
+	}
+	return query.Encode()
+}
+
+func (codec *Codec) Encode(packet *transport.Packet) ([]transport.Frame, error) {
+	switch packet.PacketType {
+		case transport.Ping:
+			return textFrame(eioPing, dataString(packet.Data)), nil
+		case transport.Pong:
+			return textFrame(eioPong, dataString(packet.Data)), nil
+		case transport.Connect:
+			return codec.message(sioConnect, packet.Endpoint, nil, map[string]string{"sid": codec.sid})
+		case transport.Disconnect:
+			return codec.message(sioDisconnect, packet.Endpoint, nil, nil)
+		case transport.ConnectError:
+			payload := map[string]interface{}{
+				"message": errorMessage(packet.Data),
+				"data": 	packet.Data,
+			}
+			return codec.message(sioConnectError, packet.Endpoint, nil, payload)
+		case transport.Error:
+			// socket.io has no error packet, errors are emitted as the "error" event
+			return codec.message(sioEvent, packet.Endpoint, nil, []interface{}{"error", packet.Data})
+		case transport.Event:
+			payload := append([]interface{}{packet.Name}, eventArgs(packet)...)
+			if packet.Seq != 0 {
+				payload = append(payload, strconv.FormatInt(packet.Seq, 10))
+			}
+			return codec.message(sioEvent, packet.Endpoint, wireId(packet.Id), payload)
+		case transport.Ack:
+			return codec.message(sioAck, packet.Endpoint, wireId(packet.Id), eventArgs(packet))
+		case transport.History:
+			if packet.Id != 0 {
+				return codec.message(sioAck, packet.Endpoint, wireId(packet.Id), []interface{}{packet.Data})
+			}
+			return codec.message(sioEvent, packet.Endpoint, nil, []interface{}{historyEvent, packet.Name, packet.Data})
+	}
+	return nil, fmt.Errorf("socketio: unsupported packet type %d", packet.PacketType)
+}
+
+func (codec *Codec) message(sioType byte, namespace string, id *int64, payload interface{}) ([]transport.Frame, error) {
+	var attachments [][]byte
+	if payload != nil && (sioType == sioEvent || sioType == sioAck) {
+		payload, attachments = extractAttachments(payload, nil)
+		if len(attachments) > 0 {
+			sioType += sioBinaryEvent - sioEvent
+		}
+	}
+
+	buffer := bytes.NewBuffer([]byte{eioMessage, sioType})
+	if len(attachments) > 0 {
+		buffer.WriteString(strconv.Itoa(len(attachments)))
+		buffer.WriteByte('-')
+	}
+	if namespace != "" && namespace != rootNamespace {
+		buffer.WriteString(namespace)
+		buffer.WriteByte(',')
+	}
+	if id != nil {
+		buffer.WriteString(strconv.FormatInt(*id, 10))
+	}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(raw)
+	}
+
+	frames := []transport.Frame{{Data: buffer.Bytes()}}
+	for _, attachment := range attachments {
+		frames = append(frames, transport.Frame{Data: attachment, Binary: true})
+	}
+	return frames, nil
+}
+
+// socket.io numbers ack ids from zero while zero means no id in gse packets,
+// the ids are shifted by one between the wire and the packets
+func packetId(wire int64) int64 {
+	return wire + 1
+}
+
+func wireId(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	wire := id - 1
+	return &wire
+}
+
+func textFrame(eioType byte, data string) []transport.Frame {
+	return []transport.Frame{{Data: append([]byte{eioType}, data...)}}
+}
+
+func dataString(data interface{}) string {
+	if s, ok := data.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func eventArgs(packet *transport.Packet) []interface{} {
+	if packet.Args != nil {
+		return packet.Args
+	}
+	if packet.Data != nil {
+		return []interface{}{packet.Data}
+	}
+	return []interface{}{}
+}
+
+func errorMessage(data interface{}) string {
+	switch v := data.(type) {
+		case error:
+			return v.Error()
+		case string:
+			return v
+	}
+	return "Connection refused"
+}
+
+// replaces byte slices with placeholders, only slices and string keyed maps are traversed
+func extractAttachments(value interface{}, attachments [][]byte) (interface{}, [][]byte) {
+	switch v := value.(type) {
+		case []byte:
+			placeholder := map[string]interface{}{"_placeholder": true, "num": len(attachments)}
+			return placeholder, append(attachments, v)
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, item := range v {
+				result[i], attachments = extractAttachments(item, attachments)
+			}
+			return result, attachments
+		case map[string]interface{}:
+			result := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				result[key], attachments = extractAttachments(item, attachments)
+			}
+			return result, attachments
+	}
+	return value, attachments
+}
+
+func replacePlaceholders(value interface{}, attachments [][]byte) (interface{}, error) {
+	switch v := value.(type) {
+		case []interface{}:
+			for i, item := range v {
+				replaced, err := replacePlaceholders(item, attachments)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = replaced
+			}
+		case map[string]interface{}:
+			if placeholder, _ := v["_placeholder"].(bool); placeholder {
+				num, ok := v["num"].(float64)
+				if !ok || int(num) < 0 || int(num) >= len(attachments) {
+					return nil, errors.New("socketio: invalid attachment placeholder")
+				}
+				return attachments[int(num)], nil
+			}
+			for key, item := range v {
+				replaced, err := replacePlaceholders(item, attachments)
+				if err != nil {
+					return nil, err
+				}
+				v[key] = replaced
+			}
+	}
+	return value, nil
+}
+
+// Probe runs the engine.io upgrade handshake on the new transport:
+// the client sends "2probe", the server answers "3probe", the pending poll
+// is released with a noop and the client completes the upgrade with "5"
+func (codec *Codec) Probe(t transport.Transport, current transport.Transport) error {
+	frame, err := t.Read()
+	if err != nil {
+		return err
+	}
+	if string(frame.Data) != string(eioPing) + probe {
+		return errors.New("Invalid upgrade probe")
+	}
+	if err := t.Write([]transport.Frame{{Data: []byte(string(eioPong) + probe)}}); err != nil {
+		return err
+	}
+	if err := current.Write([]transport.Frame{NoopFrame()}); err != nil {
+		return err
+	}
+
+	if frame, err = t.Read(); err != nil {
+		return err
+	}
+	if string(frame.Data) != string(eioUpgrade) {
+		return errors.New("Invalid upgrade packet")
+	}
+	return nil
+}
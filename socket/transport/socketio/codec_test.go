@@ -0,0 +1,459 @@
+package socketio
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func text(data string) transport.Frame {
+	return transport.Frame{Data: []byte(data)}
+}
+
+func binary(data ...byte) transport.Frame {
+	return transport.Frame{Data: data, Binary: true}
+}
+
+// frames sent by socket.io-client v2 (engine.io 3) and v4 (engine.io 4)
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name 	string
+		frames 	[]transport.Frame
+		packets []*transport.Packet
+	}{
+		{
+			name: 	"connect root",
+			frames: []transport.Frame{text("40")},
+			packets: []*transport.Packet{{PacketType: transport.Connect, Endpoint: "/"}},
+		},
+		{
+			name: 	"v2 connect namespace",
+			frames: []transport.Frame{text("40/chat")},
+			packets: []*transport.Packet{{PacketType: transport.Connect, Endpoint: "/chat"}},
+		},
+		{
+			name: 	"v2 connect namespace with query",
+			frames: []transport.Frame{text("40/chat?token=abc,")},
+			packets: []*transport.Packet{{PacketType: transport.Connect, Endpoint: "/chat", Qs: "token=abc"}},
+		},
+		{
+			name: 	"v4 connect namespace",
+			frames: []transport.Frame{text("40/chat,")},
+			packets: []*transport.Packet{{PacketType: transport.Connect, Endpoint: "/chat"}},
+		},
+		{
+			name: 	"v4 connect namespace with auth",
+			frames: []transport.Frame{text(`40/chat,{"token":"abc"}`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Connect,
+				Endpoint: 	"/chat",
+				Qs: 		"token=abc",
+				Data: 		map[string]interface{}{"token": "abc"},
+			}},
+		},
+		{
+			name: 	"disconnect namespace",
+			frames: []transport.Frame{text("41/chat,")},
+			packets: []*transport.Packet{{PacketType: transport.Disconnect, Endpoint: "/chat"}},
+		},
+		{
+			name: 	"event",
+			frames: []transport.Frame{text(`42["message","hello"]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Event,
+				Endpoint: 	"/",
+				Name: 		"message",
+				Data: 		"hello",
+				Args: 		[]interface{}{"hello"},
+			}},
+		},
+		{
+			name: 	"event with several arguments in a namespace",
+			frames: []transport.Frame{text(`42/chat,["move",1,2]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Event,
+				Endpoint: 	"/chat",
+				Name: 		"move",
+				Data: 		[]interface{}{float64(1), float64(2)},
+				Args: 		[]interface{}{float64(1), float64(2)},
+			}},
+		},
+		{
+			name: 	"event expecting an ack",
+			frames: []transport.Frame{text(`42/chat,12["sum",1]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Ack,
+				Endpoint: 	"/chat",
+				Id: 		13,
+				Name: 		"sum",
+				Data: 		float64(1),
+				Args: 		[]interface{}{float64(1)},
+			}},
+		},
+		{
+			name: 	"event expecting an ack with id zero",
+			frames: []transport.Frame{text(`420["ping","a"]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Ack,
+				Endpoint: 	"/",
+				Id: 		1,
+				Name: 		"ping",
+				Data: 		"a",
+				Args: 		[]interface{}{"a"},
+			}},
+		},
+		{
+			name: 	"ack with id zero",
+			frames: []transport.Frame{text(`430["pong"]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Ack,
+				Endpoint: 	"/",
+				Id: 		1,
+				Data: 		"pong",
+				Args: 		[]interface{}{"pong"},
+			}},
+		},
+		{
+			name: 	"ack",
+			frames: []transport.Frame{text(`43/chat,12["ok"]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.Ack,
+				Endpoint: 	"/chat",
+				Id: 		13,
+				Data: 		"ok",
+				Args: 		[]interface{}{"ok"},
+			}},
+		},
+		{
+			name: 	"binary event",
+			frames: []transport.Frame{
+				text(`451-["upload",{"_placeholder":true,"num":0}]`),
+				binary(1, 2, 3),
+			},
+			packets: []*transport.Packet{{
+				PacketType: transport.Event,
+				Endpoint: 	"/",
+				Name: 		"upload",
+				Data: 		[]byte{1, 2, 3},
+				Args: 		[]interface{}{[]byte{1, 2, 3}},
+			}},
+		},
+		{
+			name: 	"binary ack with nested attachments",
+			frames: []transport.Frame{
+				text(`462-/files,7[{"a":{"_placeholder":true,"num":0},"b":{"_placeholder":true,"num":1}}]`),
+				binary(1),
+				binary(2),
+			},
+			packets: []*transport.Packet{{
+				PacketType: transport.Ack,
+				Endpoint: 	"/files",
+				Id: 		8,
+				Data: 		map[string]interface{}{"a": []byte{1}, "b": []byte{2}},
+				Args: 		[]interface{}{map[string]interface{}{"a": []byte{1}, "b": []byte{2}}},
+			}},
+		},
+		{
+			name: 	"history request",
+			frames: []transport.Frame{text(`42/chat,5["history","lobby",{"afterId":3}]`)},
+			packets: []*transport.Packet{{
+				PacketType: transport.History,
+				Endpoint: 	"/chat",
+				Id: 		6,
+				Name: 		"lobby",
+				Data: 		map[string]interface{}{"afterId": float64(3)},
+			}},
+		},
+		{
+			name: 	"history request without query",
+			frames: []transport.Frame{text(`42["history","lobby"]`)},
+			packets: []*transport.Packet{{PacketType: transport.History, Endpoint: "/", Name: "lobby"}},
+		},
+		{
+			name: 	"upgrade probe",
+			frames: []transport.Frame{text("2probe")},
+			packets: []*transport.Packet{{PacketType: transport.Ping, Data: "probe"}},
+		},
+		{
+			name: 	"probe answer",
+			frames: []transport.Frame{text("3probe")},
+			packets: []*transport.Packet{{PacketType: transport.Pong, Data: "probe"}},
+		},
+		{
+			name: 	"v2 ping",
+			frames: []transport.Frame{text("2")},
+			packets: []*transport.Packet{{PacketType: transport.Ping, Data: ""}},
+		},
+		{
+			name: 	"v4 pong",
+			frames: []transport.Frame{text("3")},
+			packets: []*transport.Packet{{PacketType: transport.Pong, Data: ""}},
+		},
+		{
+			name: 	"upgrade and noop",
+			frames: []transport.Frame{text("5"), text("6")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codec := NewCodec("sid")
+			var packets []*transport.Packet
+			for _, frame := range test.frames {
+				decoded, err := codec.Decode(frame)
+				if err != nil {
+					t.Fatal(err)
+				}
+				packets = append(packets, decoded...)
+			}
+			if !reflect.DeepEqual(packets, test.packets) {
+				t.Fatalf("Expected %s, got %s", dump(test.packets), dump(packets))
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name 	string
+		frames 	[]transport.Frame
+	}{
+		{"empty frame", []transport.Frame{text("")}},
+		{"unknown engine.io type", []transport.Frame{text("9")}},
+		{"empty message", []transport.Frame{text("4")}},
+		{"unknown socket.io type", []transport.Frame{text("49")}},
+		{"event without payload", []transport.Frame{text("42")}},
+		{"event with object payload", []transport.Frame{text(`42{"name":"message"}`)}},
+		{"event with empty array", []transport.Frame{text("42[]")}},
+		{"event with numeric name", []transport.Frame{text("42[1]")}},
+		{"truncated json", []transport.Frame{text(`42["message"`)}},
+		{"history request without room", []transport.Frame{text(`42["history"]`)}},
+		{"history request with numeric room", []transport.Frame{text(`42["history",1]`)}},
+		{"history request with extra arguments", []transport.Frame{text(`42["history","lobby",{},1]`)}},
+		{"ack without id", []transport.Frame{text(`43["ok"]`)}},
+		{"ack with object payload", []transport.Frame{text(`431{"ok":true}`)}},
+		{"binary event without count", []transport.Frame{text(`45["upload"]`)}},
+		{"binary event with invalid count", []transport.Frame{text(`45x-["upload"]`)}},
+		{"attachment without binary packet", []transport.Frame{binary(1)}},
+		{"packet between attachments", []transport.Frame{
+			text(`452-["upload",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`),
+			binary(1),
+			text(`42["message"]`),
+		}},
+		{"placeholder out of range", []transport.Frame{
+			text(`451-["upload",{"_placeholder":true,"num":3}]`),
+			binary(1),
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codec := NewCodec("sid")
+			var err error
+			for _, frame := range test.frames {
+				if _, err = codec.Decode(frame); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name 	string
+		packet 	*transport.Packet
+		frames 	[]transport.Frame
+	}{
+		{
+			name: 	"connect root",
+			packet: &transport.Packet{PacketType: transport.Connect, Endpoint: "/"},
+			frames: []transport.Frame{text(`40{"sid":"sid"}`)},
+		},
+		{
+			name: 	"connect namespace",
+			packet: &transport.Packet{PacketType: transport.Connect, Endpoint: "/chat"},
+			frames: []transport.Frame{text(`40/chat,{"sid":"sid"}`)},
+		},
+		{
+			name: 	"disconnect",
+			packet: &transport.Packet{PacketType: transport.Disconnect, Endpoint: "/chat"},
+			frames: []transport.Frame{text("41/chat,")},
+		},
+		{
+			name: 	"connect error",
+			packet: &transport.Packet{PacketType: transport.ConnectError, Endpoint: "/chat", Data: "Unauthorized"},
+			frames: []transport.Frame{text(`44/chat,{"data":"Unauthorized","message":"Unauthorized"}`)},
+		},
+		{
+			name: 	"event",
+			packet: &transport.Packet{PacketType: transport.Event, Endpoint: "/", Name: "message", Data: "hello"},
+			frames: []transport.Frame{text(`42["message","hello"]`)},
+		},
+		{
+			name: 	"event with arguments and id",
+			packet: &transport.Packet{PacketType: transport.Event, Endpoint: "/chat", Name: "move", Id: 4, Args: []interface{}{1, 2}},
+			frames: []transport.Frame{text(`42/chat,3["move",1,2]`)},
+		},
+		{
+			name: 	"ack",
+			packet: &transport.Packet{PacketType: transport.Ack, Endpoint: "/chat", Id: 13, Data: "ok"},
+			frames: []transport.Frame{text(`43/chat,12["ok"]`)},
+		},
+		{
+			name: 	"ack with id zero",
+			packet: &transport.Packet{PacketType: transport.Ack, Endpoint: "/", Id: 1, Data: "pong"},
+			frames: []transport.Frame{text(`430["pong"]`)},
+		},
+		{
+			name: 	"error event",
+			packet: &transport.Packet{PacketType: transport.Error, Endpoint: "/", Data: "Rate limited"},
+			frames: []transport.Frame{text(`42["error","Rate limited"]`)},
+		},
+		{
+			name: 	"binary event",
+			packet: &transport.Packet{PacketType: transport.Event, Endpoint: "/", Name: "file", Args: []interface{}{[]byte{1, 2}}},
+			frames: []transport.Frame{text(`451-["file",{"_placeholder":true,"num":0}]`), binary(1, 2)},
+		},
+		{
+			name: 	"binary ack",
+			packet: &transport.Packet{PacketType: transport.Ack, Endpoint: "/files", Id: 8, Args: []interface{}{[]byte{1}, []byte{2}}},
+			frames: []transport.Frame{
+				text(`462-/files,7[{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`),
+				binary(1),
+				binary(2),
+			},
+		},
+		{
+			name: 	"recorded event with seq",
+			packet: &transport.Packet{PacketType: transport.Event, Endpoint: "/chat", Name: "message", Data: "hi", Seq: 7},
+			frames: []transport.Frame{text(`42/chat,["message","hi","7"]`)},
+		},
+		{
+			name: 	"reliable event with id",
+			packet: &transport.Packet{PacketType: transport.Event, Endpoint: "/", Name: "order", Id: 5, Data: "paid"},
+			frames: []transport.Frame{text(`424["order","paid"]`)},
+		},
+		{
+			name: 	"history answering a request",
+			packet: &transport.Packet{
+				PacketType: transport.History,
+				Endpoint: 	"/chat",
+				Name: 		"lobby",
+				Id: 		6,
+				Data: 		[]interface{}{map[string]interface{}{"id": 4, "name": "message"}},
+			},
+			frames: []transport.Frame{text(`43/chat,5[[{"id":4,"name":"message"}]]`)},
+		},
+		{
+			name: 	"history without a request",
+			packet: &transport.Packet{
+				PacketType: transport.History,
+				Endpoint: 	"/chat",
+				Name: 		"lobby",
+				Data: 		[]interface{}{},
+			},
+			frames: []transport.Frame{text(`42/chat,["history","lobby",[]]`)},
+		},
+		{
+			name: 	"probe answer",
+			packet: &transport.Packet{PacketType: transport.Pong, Data: "probe"},
+			frames: []transport.Frame{text("3probe")},
+		},
+		{
+			name: 	"ping",
+			packet: &transport.Packet{PacketType: transport.Ping},
+			frames: []transport.Frame{text("2")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames, err := NewCodec("sid").Encode(test.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(frames, test.frames) {
+				t.Fatalf("Expected %s, got %s", frameString(test.frames), frameString(frames))
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	upgrade := newFakeTransport(text("2probe"), text("5"))
+	current := newFakeTransport()
+	if err := NewCodec("sid").Probe(upgrade, current); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(upgrade.written, []transport.Frame{text("3probe")}) {
+		t.Fatalf("Expected the probe answer, got %s", frameString(upgrade.written))
+	}
+	if !reflect.DeepEqual(current.written, []transport.Frame{NoopFrame()}) {
+		t.Fatalf("Expected the pending poll to be released, got %s", frameString(current.written))
+	}
+
+	if err := NewCodec("sid").Probe(newFakeTransport(text("2ping")), newFakeTransport()); err == nil {
+		t.Fatal("Expected an invalid probe to fail")
+	}
+	if err := NewCodec("sid").Probe(newFakeTransport(text("2probe"), text("6")), newFakeTransport()); err == nil {
+		t.Fatal("Expected an invalid upgrade packet to fail")
+	}
+}
+
+// transport reading the given frames and recording the written ones
+type fakeTransport struct {
+	frames 	[]transport.Frame
+	written []transport.Frame
+}
+
+func newFakeTransport(frames ...transport.Frame) *fakeTransport {
+	return &fakeTransport{frames: frames}
+}
+
+func (conn *fakeTransport) Name() string {
+	return "fake"
+}
+
+func (conn *fakeTransport) Read() (transport.Frame, error) {
+	if len(conn.frames) == 0 {
+		return transport.Frame{}, transport.ErrTransportClosed
+	}
+	frame := conn.frames[0]
+	conn.frames = conn.frames[1:]
+	return frame, nil
+}
+
+func (conn *fakeTransport) Write(frames []transport.Frame) error {
+	conn.written = append(conn.written, frames...)
+	return nil
+}
+
+func (conn *fakeTransport) Close() error {
+	return nil
+}
+
+func dump(packets []*transport.Packet) string {
+	raw := "["
+	for _, packet := range packets {
+		encoded, _ := transport.Encode(packet)
+		raw += string(encoded)
+	}
+	return raw + "]"
+}
+
+func frameString(frames []transport.Frame) string {
+	raw := "["
+	for _, frame := range frames {
+		if frame.Binary {
+			raw += fmt.Sprintf(" %v", frame.Data)
+		} else {
+			raw += fmt.Sprintf(" %q", frame.Data)
+		}
+	}
+	return raw + " ]"
+}
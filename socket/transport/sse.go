@@ -0,0 +1,242 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SSETransport = "sse"
+
+var ErrStreamExpired = errors.New("Event stream expired")
+
+type SSEConf struct {
+	// number of events kept for Last-Event-ID replay
+	BufferSize 	int
+	// duration for which a detached stream is kept for the browser to reconnect
+	Retention 	time.Duration
+	// interval of the comments keeping idle connections open, zero disables them
+	KeepAlive 	time.Duration
+}
+
+type sseEvent struct {
+	seq 	uint64
+	data 	[]byte
+}
+
+// SSE is a receive-only Server-Sent Events transport, every frame is sent as an event with
+// the id "<stream>-<sequence>" so a reconnecting browser can be attached to the same stream
+// and receive the events it missed through the Last-Event-ID header
+type SSE struct {
+	// stream id
+	stream 		string
+	conf 		SSEConf
+	// last sent events, bounded by the buffer size
+	buffer 		[]sseEvent
+	// sequence number of the last event
+	seq 		uint64
+	// attached response, nil while detached
+	w 			http.ResponseWriter
+	// closed when the attached response is detached
+	detachc 	chan struct{}
+	// closed when the transport is closed
+	closec 		chan struct{}
+	// reason of closing
+	err 		error
+	// closes streams which were not reattached in time
+	retention 	*time.Timer
+	// lock
+	mtx 		*sync.Mutex
+}
+
+func NewSSE(stream string, conf SSEConf) *SSE {
+	sse := &SSE{
+		stream: 	stream,
+		conf: 		conf,
+		buffer: 	make([]sseEvent, 0, conf.BufferSize),
+		closec: 	make(chan struct{}),
+		mtx: 		new(sync.Mutex),
+	}
+	sse.retention = time.AfterFunc(conf.Retention, func() {
+		sse.close(ErrStreamExpired)
+	})
+	sse.retention.Stop()
+	return sse
+}
+
+func (sse *SSE) Name() string {
+	return SSETransport
+}
+
+// Read blocks until the transport is closed, the stream doesn't receive frames
+func (sse *SSE) Read() (Frame, error) {
+	<-sse.closec
+	sse.mtx.Lock()
+	defer sse.mtx.Unlock()
+	return Frame{}, sse.err
+}
+
+// Write sends the frames to the attached response and keeps them for replay,
+// frames written while the stream is detached are sent when it is reattached
+func (sse *SSE) Write(frames []Frame) error {
+	sse.mtx.Lock()
+	defer sse.mtx.Unlock()
+	if sse.err != nil {
+		return sse.err
+	}
+	for _, frame := range frames {
+		sse.seq++
+		event := sseEvent{seq: sse.seq, data: frameData(frame)}
+		if sse.conf.BufferSize > 0 {
+			if len(sse.buffer) == sse.conf.BufferSize {
+				copy(sse.buffer, sse.buffer[1:])
+				sse.buffer = sse.buffer[:len(sse.buffer) - 1]
+			}
+			sse.buffer = append(sse.buffer, event)
+		}
+		if sse.w != nil {
+			if _, err := sse.w.Write(sse.encode(event)); err != nil {
+				sse.detach()
+			}
+		}
+	}
+	if sse.w != nil {
+		sse.w.(http.Flusher).Flush()
+	}
+	return nil
+}
+
+func (sse *SSE) Close() error {
+	sse.close(ErrTransportClosed)
+	return nil
+}
+
+func (sse *SSE) close(err error) {
+	sse.mtx.Lock()
+	defer sse.mtx.Unlock()
+	if sse.err != nil {
+		return
+	}
+	sse.err = err
+	sse.retention.Stop()
+	close(sse.closec)
+}
+
+// Serve attaches the response to the stream, replays the buffered events after
+// the sequence number and blocks until the request ends or the stream is closed,
+// the response must implement http.Flusher
+func (sse *SSE) Serve(w http.ResponseWriter, r *http.Request, lastSeq uint64) {
+	sse.mtx.Lock()
+	if sse.err != nil {
+		sse.mtx.Unlock()
+		http.Error(w, sse.err.Error(), http.StatusGone)
+		return
+	}
+	// a newer request replaces the attached one
+	if sse.w != nil {
+		sse.detach()
+	}
+	sse.retention.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range sse.buffer {
+		if event.seq > lastSeq {
+			w.Write(sse.encode(event))
+		}
+	}
+	w.(http.Flusher).Flush()
+	detachc := make(chan struct{})
+	sse.w, sse.detachc = w, detachc
+	sse.mtx.Unlock()
+
+	var tick <-chan time.Time
+	if sse.conf.KeepAlive > 0 {
+		ticker := time.NewTicker(sse.conf.KeepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+			case <-tick:
+				sse.keepAlive(detachc)
+			case <-r.Context().Done():
+				sse.mtx.Lock()
+				if sse.detachc == detachc {
+					sse.detach()
+				}
+				sse.mtx.Unlock()
+				return
+			case <-detachc:
+				return
+			case <-sse.closec:
+				return
+		}
+	}
+}
+
+func (sse *SSE) keepAlive(detachc chan struct{}) {
+	sse.mtx.Lock()
+	defer sse.mtx.Unlock()
+	if sse.detachc != detachc {
+		return
+	}
+	if _, err := sse.w.Write([]byte(":\n\n")); err != nil {
+		sse.detach()
+		return
+	}
+	sse.w.(http.Flusher).Flush()
+}
+
+// detaches the response and starts the retention timer, must be called with the lock held
+func (sse *SSE) detach() {
+	close(sse.detachc)
+	sse.w, sse.detachc = nil, nil
+	if sse.err == nil {
+		sse.retention.Reset(sse.conf.Retention)
+	}
+}
+
+func (sse *SSE) encode(event sseEvent) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("id: ")
+	buffer.WriteString(sse.stream)
+	buffer.WriteByte('-')
+	buffer.WriteString(strconv.FormatUint(event.seq, 10))
+	buffer.WriteByte('\n')
+	for _, line := range bytes.Split(event.data, []byte{'\n'}) {
+		buffer.WriteString("data: ")
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
+
+// binary frames are sent base64 encoded with the "b" prefix as in polling payloads
+func frameData(frame Frame) []byte {
+	if frame.Binary {
+		return []byte("b" + base64.StdEncoding.EncodeToString(frame.Data))
+	}
+	return frame.Data
+}
+
+// ParseEventId splits a Last-Event-ID into the stream id and the sequence number
+func ParseEventId(id string) (string, uint64, bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(id[i + 1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
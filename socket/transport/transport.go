@@ -0,0 +1,30 @@
+package transport
+
+import (
+	"errors"
+)
+
+const(
+	WebSocketTransport 	= "websocket"
+	PollingTransport 	= "polling"
+)
+
+var ErrTransportClosed = errors.New("Transport closed")
+
+// Transport carries the frames of a client connection, Read is called only
+// from the client read loop and Write only from the client write loop
+type Transport interface {
+	// name of the transport, e.g. "websocket" or "polling"
+	Name() string
+	// blocks until a frame is received
+	Read() (Frame, error)
+	// writes the frames in order
+	Write([]Frame) error
+	Close() error
+}
+
+// Prober is implemented by codecs which verify a transport before the client is upgraded to it,
+// the current transport is only written to, e.g. to release a pending poll
+type Prober interface {
+	Probe(upgrade Transport, current Transport) error
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket is a transport over a websocket connection
+type WebSocket struct {
+	conn 	*websocket.Conn
+}
+
+func NewWebSocket(conn *websocket.Conn) *WebSocket {
+	return &WebSocket{conn: conn}
+}
+
+func (ws *WebSocket) Name() string {
+	return WebSocketTransport
+}
+
+func (ws *WebSocket) Read() (Frame, error) {
+	messageType, msg, err := ws.conn.ReadMessage()
+	if err != nil {
+		return Frame{}, err
+	}
+	return Frame{
+		Data: 	msg,
+		Binary: messageType == websocket.BinaryMessage,
+	}, nil
+}
+
+func (ws *WebSocket) Write(frames []Frame) error {
+	for _, frame := range frames {
+		messageType := websocket.TextMessage
+		if frame.Binary {
+			messageType = websocket.BinaryMessage
+		}
+		if err := ws.conn.WriteMessage(messageType, frame.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,186 @@
+package socket
+
+import (
+	"github.com/ppincak/gse/socket/transport"
+)
+
+// SetUser binds the client to the user, usually during authorization, so it can be
+// reached with ToUser, an empty id removes the binding. A client opened with the resume
+// query parameter takes over the reliable messages of the session if it belonged to the user.
+func (client *Client) SetUser(userId string) {
+	server := client.server
+	// the index lock is held across the swap so concurrent bindings
+	// and the removal of a closing client can't interleave
+	server.usersMtx.Lock()
+	client.mtx.Lock()
+	previous, open, resumeId := client.userId, client.open, client.resumeId
+	client.userId = userId
+	if userId != "" {
+		client.resumeId = ""
+	}
+	client.mtx.Unlock()
+
+	server.unbindUser(previous, client)
+	// a closed client was already removed from the index
+	if open {
+		server.bindUser(userId, client)
+	}
+	server.usersMtx.Unlock()
+
+	if open && userId != "" && resumeId != "" && server.claimOutbox(client, resumeId, userId) {
+		go client.resend("")
+	}
+}
+
+// UserId returns the id of the user bound to the client, empty if there is none
+func (client *Client) UserId() string {
+	client.mtx.RLock()
+	defer client.mtx.RUnlock()
+	return client.userId
+}
+
+// must be called with the user index lock held
+func (server *Server) bindUser(userId string, client *Client) {
+	if userId == "" {
+		return
+	}
+	sessions, ok := server.users[userId]
+	if !ok {
+		sessions = make(map[string]*Client)
+		server.users[userId] = sessions
+	}
+	sessions[client.uuid] = client
+}
+
+// must be called with the user index lock held
+func (server *Server) unbindUser(userId string, client *Client) {
+	if userId == "" {
+		return
+	}
+	if sessions, ok := server.users[userId]; ok {
+		delete(sessions, client.uuid)
+		if len(sessions) == 0 {
+			delete(server.users, userId)
+		}
+	}
+}
+
+// removes the closed client from the user index
+func (server *Server) releaseUser(client *Client) {
+	server.usersMtx.Lock()
+	server.unbindUser(client.UserId(), client)
+	server.usersMtx.Unlock()
+}
+
+// GetUserClients returns the connected sessions of the user
+func (server *Server) GetUserClients(userId string) []*Client {
+	server.usersMtx.RLock()
+	defer server.usersMtx.RUnlock()
+	sessions := server.users[userId]
+	clients := make([]*Client, 0, len(sessions))
+	for _, client := range sessions {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Target sends events to selected clients in a namespace, clients which
+// are not connected to the namespace are skipped
+type Target struct {
+	server 		*Server
+	// selects the clients when sending
+	clients 	func() []*Client
+	// namespace of the sent events
+	namespace 	string
+}
+
+// ToUser targets all the sessions of the user in the root namespace
+func (server *Server) ToUser(userId string) *Target {
+	return &Target{
+		server: 	server,
+		clients: 	func() []*Client {
+			return server.GetUserClients(userId)
+		},
+		namespace: 	server.Namespace.name,
+	}
+}
+
+// ToSession targets the client with the session id in the root namespace, the session
+// stays addressable with In after the client left the root namespace
+func (server *Server) ToSession(sessionId string) *Target {
+	return &Target{
+		server: 	server,
+		clients: 	func() []*Client {
+			if client := server.GetSession(sessionId); client != nil {
+				return []*Client{client}
+			}
+			return nil
+		},
+		namespace: 	server.Namespace.name,
+	}
+}
+
+// In returns the target sending to the namespace
+func (target *Target) In(namespaceName string) *Target {
+	copied := *target
+	copied.namespace = namespaceName
+	return &copied
+}
+
+// GetClients returns the targeted clients connected to the namespace
+func (target *Target) GetClients() []*Client {
+	clients := make([]*Client, 0)
+	for _, client := range target.clients() {
+		if client.getNamespace(target.namespace) != nil {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func (target *Target) getNamespace() *Namespace {
+	if target.namespace == target.server.Namespace.name {
+		return target.server.Namespace
+	}
+	target.server.nsMtx.RLock()
+	defer target.server.nsMtx.RUnlock()
+	return target.server.namespaces[target.namespace]
+}
+
+// Emit sends the event with positional arguments, returns the number of clients it was sent to
+func (target *Target) Emit(event string, args ...interface{}) int {
+	namespace := target.getNamespace()
+	if namespace == nil {
+		return 0
+	}
+	clients := target.GetClients()
+	namespace.emit(target.server.Context(), clients, eventPacket(event, namespace, args))
+	return len(clients)
+}
+
+// SendEvent sends the event, returns the number of clients it was sent to
+func (target *Target) SendEvent(event string, data interface{}) int {
+	namespace := target.getNamespace()
+	if namespace == nil {
+		return 0
+	}
+	clients := target.GetClients()
+	namespace.emit(target.server.Context(), clients, &transport.Packet{
+		Name: 		event,
+		Data: 		data,
+		PacketType: transport.Event,
+		Endpoint: 	namespace.name,
+	})
+	return len(clients)
+}
+
+// EmitReliable sends the event at least once, returns the number of clients it was sent to
+func (target *Target) EmitReliable(event string, args ...interface{}) int {
+	namespace := target.getNamespace()
+	if namespace == nil {
+		return 0
+	}
+	clients := target.GetClients()
+	namespace.emitReliable(target.server.Context(), clients, eventPacket(event, namespace, args))
+	return len(clients)
+}
@@ -0,0 +1,107 @@
+package socket
+
+import (
+	"testing"
+	"time"
+	"github.com/ppincak/gse/socket/transport"
+)
+
+func expectTestEvent(t *testing.T, conn *testTransport, namespaceName string, event string) {
+	packet := conn.expect(t, transport.Event)
+	if packet.Endpoint != namespaceName || packet.Name != event {
+		t.Fatalf("Expected %s in %s, got %+v", event, namespaceName, packet)
+	}
+}
+
+func TestToUserFanOut(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	alice1, aliceConn1 := openTestConn(t, server, "/")
+	alice2, aliceConn2 := openTestConn(t, server, "/")
+	bob, bobConn := openTestConn(t, server, "/")
+	alice1.SetUser("alice")
+	alice2.SetUser("alice")
+	bob.SetUser("bob")
+
+	if sent := server.ToUser("alice").Emit("notice", 1); sent != 2 {
+		t.Fatalf("Expected 2 recipients, got %d", sent)
+	}
+	expectTestEvent(t, aliceConn1, "/", "notice")
+	expectTestEvent(t, aliceConn2, "/", "notice")
+	bobConn.expectNone(t, transport.Event, 20 * time.Millisecond)
+
+	if sent := server.ToUser("carol").SendEvent("notice", nil); sent != 0 {
+		t.Fatalf("Expected no recipients for an unknown user, got %d", sent)
+	}
+}
+
+func TestToUserIn(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	if _, err := server.AddNamespace("/chat", nil); err != nil {
+		t.Fatal(err)
+	}
+	member, memberConn := openTestConn(t, server, "/")
+	outsider, outsiderConn := openTestConn(t, server, "/")
+	member.SetUser("alice")
+	outsider.SetUser("alice")
+	connectTestClient(t, member, "/chat")
+
+	if sent := server.ToUser("alice").In("/chat").Emit("message", "hi"); sent != 1 {
+		t.Fatalf("Expected only the session in the namespace, got %d", sent)
+	}
+	expectTestEvent(t, memberConn, "/chat", "message")
+	outsiderConn.expectNone(t, transport.Event, 20 * time.Millisecond)
+
+	if sent := server.ToUser("alice").In("/missing").Emit("message"); sent != 0 {
+		t.Fatalf("Expected no recipients in a missing namespace, got %d", sent)
+	}
+}
+
+func TestUserBindingChanges(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	client := openTestClient(t, server, "/")
+	other := openTestClient(t, server, "/")
+	client.SetUser("alice")
+	other.SetUser("alice")
+
+	client.SetUser("bob")
+	if len(server.GetUserClients("alice")) != 1 || len(server.GetUserClients("bob")) != 1 || client.UserId() != "bob" {
+		t.Fatal("Expected the client to move to the new user")
+	}
+	client.SetUser("")
+	if len(server.GetUserClients("bob")) != 0 || client.UserId() != "" {
+		t.Fatal("Expected the binding to be removed")
+	}
+
+	other.Disconnect()
+	if sent := server.ToUser("alice").Emit("notice"); sent != 0 {
+		t.Fatalf("Expected the disconnected session to be skipped, got %d", sent)
+	}
+	other.SetUser("carol")
+	if len(server.GetUserClients("carol")) != 0 {
+		t.Fatal("Expected a closed client not to be bound")
+	}
+}
+
+func TestToSession(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Stop()
+	client, conn := openTestConn(t, server, "/")
+	_, otherConn := openTestConn(t, server, "/")
+
+	if sent := server.ToSession(client.GetSessionId()).SendEvent("private", "secret"); sent != 1 {
+		t.Fatalf("Expected a single recipient, got %d", sent)
+	}
+	expectTestEvent(t, conn, "/", "private")
+	otherConn.expectNone(t, transport.Event, 20 * time.Millisecond)
+
+	if sent := server.ToSession("missing").Emit("private"); sent != 0 {
+		t.Fatalf("Expected no recipients for an unknown session, got %d", sent)
+	}
+	client.Disconnect()
+	if sent := server.ToSession(client.GetSessionId()).Emit("private"); sent != 0 {
+		t.Fatalf("Expected no recipients after the disconnect, got %d", sent)
+	}
+}
@@ -0,0 +1,88 @@
+package socket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeYamlConf(t *testing.T) {
+	input := `
+# server configuration
+serverName: gse
+numberOfClients: 10
+handshakeTimeout: 5s
+allowedOrigins:
+  - https://a.com
+  - 'https://*.b.com'
+responseHeaders: {X-Frame-Options: DENY}
+rateLimit: {policy: drop, events: {chat: {eventsPerSecond: 1, eventBurst: 2}}}
+reliable:
+  maxPending: 5
+`
+	conf := DefaultConf()
+	if err := decodeConf([]byte(input), ".yml", conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.ServerName != "gse" || conf.MaxNumOfClients != 10 {
+		t.Fatalf("Unexpected scalars %q %d", conf.ServerName, conf.MaxNumOfClients)
+	}
+	if time.Duration(conf.HandshakeTimeout) != 5 * time.Second {
+		t.Fatalf("Expected handshake timeout 5s, got %s", time.Duration(conf.HandshakeTimeout))
+	}
+	if !reflect.DeepEqual(conf.AllowedOrigins, []string{"https://a.com", "https://*.b.com"}) {
+		t.Fatalf("Unexpected origins %v", conf.AllowedOrigins)
+	}
+	if conf.ResponseHeaders["X-Frame-Options"] != "DENY" {
+		t.Fatalf("Unexpected headers %v", conf.ResponseHeaders)
+	}
+	if conf.RateLimit == nil || conf.RateLimit.Policy != DropPolicy {
+		t.Fatalf("Unexpected rate limit %+v", conf.RateLimit)
+	}
+	if event := conf.RateLimit.Events["chat"]; event.EventsPerSecond != 1 || event.EventBurst != 2 {
+		t.Fatalf("Unexpected event limit %+v", event)
+	}
+	if conf.Reliable == nil || conf.Reliable.MaxPending != 5 {
+		t.Fatalf("Unexpected reliable configuration %+v", conf.Reliable)
+	}
+}
+
+func TestDecodeYamlBlockScalar(t *testing.T) {
+	input := "serverName: >-\n  folded\n  name\nresponseHeaders:\n  X-Note: |\n    line one\n    line two\n"
+
+	conf := DefaultConf()
+	if err := decodeConf([]byte(input), ".yaml", conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.ServerName != "folded name" {
+		t.Fatalf("Expected folded scalar, got %q", conf.ServerName)
+	}
+	if note := conf.ResponseHeaders["X-Note"]; note != "line one\nline two\n" {
+		t.Fatalf("Expected literal scalar, got %q", note)
+	}
+}
+
+func TestDecodeYamlConfErrors(t *testing.T) {
+	tests := []struct {
+		name 	string
+		input 	string
+	}{
+		{"duplicate key", "serverName: a\nserverName: b\n"},
+		{"duplicate nested key", "rateLimit:\n  policy: drop\n  policy: error\n"},
+		{"duplicate flow key", "rateLimit: {events: {chat: {eventBurst: 1, eventBurst: 2}}}\n"},
+		{"tab indentation", "rateLimit:\n\tpolicy: drop\n"},
+		{"unterminated flow mapping", "rateLimit: {policy: drop\n"},
+		{"unterminated quote", "serverName: \"gse\n"},
+		{"mapping key", "responseHeaders: {{a: 1}: b}\n"},
+		{"wrong type", "numberOfClients: many\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := decodeConf([]byte(test.input), ".yaml", DefaultConf()); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}